@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func registerAdminHandlers(mux *http.ServeMux, host string) {
+	mux.HandleFunc("/status", statusHandler)
+
+	if len(conf.AdminToken) < 1 {
+		if !isLoopback(host) {
+			log.Warnf("Admin endpoints are disabled, set AdminToken to use them while listening on %q", host)
+			return
+		}
+
+		log.Warn("AdminToken isn't set, admin endpoints are open to anyone who can connect locally")
+	}
+
+	mux.HandleFunc("/admin/maintenance", adminOnly(maintenanceHandler))
+	mux.HandleFunc("/admin/backup", adminOnly(backupHandler))
+	mux.HandleFunc("/admin/history", adminOnly(historyHandler))
+	mux.HandleFunc("/admin/rollback", adminOnly(rollbackHandler))
+	mux.HandleFunc("/admin/edit", adminOnly(editHandler))
+	mux.HandleFunc("/admin/report", adminOnly(reportHandler))
+	mux.HandleFunc("/admin/pin", adminOnly(pinHandler))
+}
+
+// isLoopback reports whether host only accepts connections from this machine, empty host listens on all interfaces
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// adminOnly rejects requests that aren't JSON POST or don't carry configured admin token. Browsers can't send
+// JSON cross-origin without a preflight, so tokenless loopback endpoints can't be reached through CSRF
+func adminOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+			http.Error(w, "content type must be application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		if len(conf.AdminToken) > 0 && !validToken(r.Header.Get("Authorization")) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// validToken compares authorization header in constant time so the token can't be guessed from response timing
+func validToken(header string) bool {
+	return subtle.ConstantTimeCompare([]byte(header), []byte("Bearer "+conf.AdminToken)) == 1
+}
+
+func maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := maintenance.Run()
+	if err != nil {
+		log.Errorf("Cache maintenance failed: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, report)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warnf("Failed to write response: %v", err)
+	}
+}
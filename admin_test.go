@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gitgud.io/softashell/comfy-translator/config"
+)
+
+func TestRegisterAdminHandlers(t *testing.T) {
+	defer func(c *config.Config) { conf = c }(conf)
+
+	tests := []struct {
+		name  string
+		host  string
+		token string
+		want  bool
+	}{
+		{"loopback without token", "127.0.0.1", "", true},
+		{"localhost without token", "localhost", "", true},
+		{"ipv6 loopback without token", "::1", "", true},
+		{"all interfaces without token", "", "", false},
+		{"public without token", "0.0.0.0", "", false},
+		{"public with token", "0.0.0.0", "secret", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf = &config.Config{AdminToken: tt.token}
+
+			mux := http.NewServeMux()
+			registerAdminHandlers(mux, tt.host)
+
+			_, pattern := mux.Handler(httptest.NewRequest(http.MethodPost, "/admin/edit", nil))
+			if got := pattern != ""; got != tt.want {
+				t.Errorf("admin endpoints registered = %v, want %v", got, tt.want)
+			}
+
+			if _, pattern := mux.Handler(httptest.NewRequest(http.MethodGet, "/status", nil)); pattern == "" {
+				t.Error("status endpoint should always be registered")
+			}
+		})
+	}
+}
+
+func TestAdminOnly(t *testing.T) {
+	defer func(c *config.Config) { conf = c }(conf)
+
+	tests := []struct {
+		name          string
+		token         string
+		method        string
+		contentType   string
+		authorization string
+		want          int
+	}{
+		{"json without token", "", http.MethodPost, "application/json", "", http.StatusOK},
+		{"json with charset", "", http.MethodPost, "application/json; charset=utf-8", "", http.StatusOK},
+		{"get", "", http.MethodGet, "application/json", "", http.StatusMethodNotAllowed},
+		{"plain text form", "", http.MethodPost, "text/plain", "", http.StatusUnsupportedMediaType},
+		{"url encoded form", "", http.MethodPost, "application/x-www-form-urlencoded", "", http.StatusUnsupportedMediaType},
+		{"missing content type", "", http.MethodPost, "", "", http.StatusUnsupportedMediaType},
+		{"valid token", "secret", http.MethodPost, "application/json", "Bearer secret", http.StatusOK},
+		{"wrong token", "secret", http.MethodPost, "application/json", "Bearer secreT", http.StatusUnauthorized},
+		{"missing token", "secret", http.MethodPost, "application/json", "", http.StatusUnauthorized},
+		{"token without json", "secret", http.MethodPost, "text/plain", "Bearer secret", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf = &config.Config{AdminToken: tt.token}
+
+			h := adminOnly(func(w http.ResponseWriter, r *http.Request) {})
+
+			r := httptest.NewRequest(tt.method, "/admin/edit", nil)
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			h(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"gitgud.io/softashell/comfy-translator/cache"
+)
+
+func backupHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := backups.Run()
+	if err != nil {
+		log.Errorf("Cache backup failed: %v", err)
+
+		status := http.StatusInternalServerError
+		if errors.Is(err, cache.ErrBackupUnsupported) {
+			status = http.StatusNotImplemented
+		}
+
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	writeJSON(w, report)
+}
+
+func backupCommand(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := fs.String("out", "", "File to write backup to, by default it's written to configured directory and old backups are rotated")
+	fs.Parse(args)
+
+	// Safe to run next to the server, it only reads a snapshot of the database
+	c, err := openCache()
+	if err != nil {
+		return errors.Wrap(err, "failed to open cache")
+	}
+	defer c.Close()
+
+	if *out != "" {
+		size, err := cache.Backup(c, *out)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Wrote %d bytes to %s\n", size, *out)
+
+		return nil
+	}
+
+	report, err := cache.NewBackups(c, conf).Run()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %d bytes to %s\n", report.Size, report.Path)
+	for _, f := range report.Removed {
+		fmt.Printf("Removed %s\n", f)
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"gitgud.io/softashell/comfy-translator/config"
+)
+
+// ErrBackupUnsupported is returned when database engine can't be backed up while running
+var ErrBackupUnsupported = fmt.Errorf("database engine doesn't support online backups, use its own tools instead")
+
+// Microseconds keep backups written within the same second from colliding
+const backupTimeFormat = "20060102-150405.000000"
+
+// BackupReport describes a single written backup
+type BackupReport struct {
+	Path    string   `json:"path"`
+	Size    int64    `json:"size"`
+	Removed []string `json:"removed,omitempty"`
+	Took    string   `json:"took"`
+}
+
+// Backups periodically writes snapshots of the database into a directory and deletes old ones
+type Backups struct {
+	c Cache
+
+	interval time.Duration
+	dir      string
+	prefix   string
+	keep     int
+
+	lock *sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewBackups(c Cache, conf *config.Config) *Backups {
+	base := filepath.Base(conf.Database.Sqlite.Path)
+
+	return &Backups{
+		c:        c,
+		interval: conf.Database.Backup.Interval.Duration(),
+		dir:      conf.Database.Backup.Dir,
+		prefix:   strings.TrimSuffix(base, filepath.Ext(base)) + "-",
+		keep:     conf.Database.Backup.Keep,
+		lock:     &sync.Mutex{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start writes backups on configured interval until Stop is called
+func (b *Backups) Start() {
+	if b.interval <= 0 {
+		log.Info("Scheduled cache backups disabled")
+		close(b.done)
+		return
+	}
+
+	if _, ok := backend(b.c).(Backuper); !ok {
+		log.Warn("Scheduled cache backups disabled: ", ErrBackupUnsupported)
+		close(b.done)
+		return
+	}
+
+	log.Infof("Writing cache backup to %s every %s", b.dir, b.interval)
+
+	go func() {
+		defer close(b.done)
+
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := b.Run(); err != nil {
+					log.Errorf("Cache backup failed: %v", err)
+				}
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop waits for running backup to finish and stops the scheduler
+func (b *Backups) Stop() {
+	close(b.stop)
+	<-b.done
+}
+
+// Run writes backup right now and deletes the oldest ones over the limit, concurrent calls wait for each other
+func (b *Backups) Run() (BackupReport, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var report BackupReport
+
+	start := time.Now()
+
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return report, err
+	}
+
+	report.Path = filepath.Join(b.dir, b.prefix+start.UTC().Format(backupTimeFormat)+".db")
+
+	var err error
+
+	report.Size, err = Backup(b.c, report.Path)
+	if err != nil {
+		return report, err
+	}
+
+	report.Removed, err = b.rotate()
+	if err != nil {
+		return report, err
+	}
+
+	report.Took = time.Since(start).String()
+
+	log.WithFields(log.Fields{
+		"time":    report.Took,
+		"size":    report.Size,
+		"removed": len(report.Removed),
+	}).Infof("Wrote cache backup to %s", report.Path)
+
+	return report, nil
+}
+
+// rotate deletes all but the newest backups, 0 keeps everything
+func (b *Backups) rotate() ([]string, error) {
+	if b.keep <= 0 {
+		return nil, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(b.dir, b.prefix+"*.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, f := range files {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(f), b.prefix), ".db")
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, f)
+		}
+	}
+
+	if len(backups) <= b.keep {
+		return nil, nil
+	}
+
+	// Timestamps in names sort the same way as the time they were written at
+	sort.Strings(backups)
+
+	var removed []string
+	for _, f := range backups[:len(backups)-b.keep] {
+		if err := os.Remove(f); err != nil {
+			return removed, err
+		}
+
+		removed = append(removed, f)
+	}
+
+	return removed, nil
+}
+
+// Backup writes consistent copy of the database to path and returns its size,
+// copy is written next to it first so a failed backup never leaves a broken file behind
+func Backup(c Cache, path string) (int64, error) {
+	backuper, ok := backend(c).(Backuper)
+	if !ok {
+		return 0, ErrBackupUnsupported
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return 0, fmt.Errorf("%s already exists", path)
+	}
+
+	// Buffered writes would be missing from backup otherwise
+	if t, ok := c.(*tieredCache); ok && t.buffer != nil {
+		if err := t.buffer.flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+
+	if err := backuper.Backup(tmp); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
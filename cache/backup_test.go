@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+// backupStore pretends to back up memory store
+type backupStore struct {
+	*memoryStore
+}
+
+func (b backupStore) Backup(path string) error {
+	return ioutil.WriteFile(path, []byte("backup"), 0644)
+}
+
+func TestBackups_Run(t *testing.T) {
+	dir := t.TempDir()
+
+	// Older backups and unrelated files in the same directory
+	for _, name := range []string{"translation-20200101-000000.000000.db", "translation-20200102-000000.000000.db", "translation-20200103-000000.000000.db", "translation-notes.db", "other.db"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, _ := newTieredCache(backupStore{newMemoryStore()}, testPolicy, []string{"Bing"})
+
+	b := &Backups{c: c, dir: dir, prefix: "translation-", keep: 2, lock: &sync.Mutex{}}
+
+	report, err := b.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Size != int64(len("backup")) {
+		t.Errorf("Size = %d", report.Size)
+	}
+
+	if len(report.Removed) != 2 {
+		t.Errorf("Removed = %v, want 2 oldest backups", report.Removed)
+	}
+
+	var left []string
+	files, _ := ioutil.ReadDir(dir)
+	for _, f := range files {
+		left = append(left, f.Name())
+	}
+
+	want := []string{"other.db", "translation-20200103-000000.000000.db", filepath.Base(report.Path), "translation-notes.db"}
+	if len(left) != len(want) {
+		t.Fatalf("files left = %v, want %v", left, want)
+	}
+	for _, name := range want {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was removed", name)
+		}
+	}
+}
+
+func TestBackups_RunWriteBehind(t *testing.T) {
+	store := newMemoryStore()
+
+	c, _ := newTieredCache(backupStore{store}, testPolicy, []string{"Bing"})
+	c.buffer = &writeBehind{store: store, batchSize: 10, maxPending: 10, lock: &sync.Mutex{}, pending: make(map[entry.Key]entry.Entry), flushLock: &sync.Mutex{}}
+
+	if err := c.Put("Bing", translator.Request{Text: "a", From: "ja", To: "en"}, "A", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &Backups{c: c, dir: t.TempDir(), prefix: "translation-", lock: &sync.Mutex{}}
+
+	first, err := b.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.entries) != 1 {
+		t.Errorf("%d entries stored before backup, want buffered entry written", len(store.entries))
+	}
+
+	// Backups right after each other still get their own file
+	second, err := b.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Path == second.Path {
+		t.Errorf("both backups written to %s", first.Path)
+	}
+}
+
+func TestBackup_Unsupported(t *testing.T) {
+	c, _ := newTieredCache(newMemoryStore(), testPolicy, []string{"Bing"})
+
+	if _, err := Backup(c, filepath.Join(t.TempDir(), "backup.db")); err != ErrBackupUnsupported {
+		t.Errorf("Backup() error = %v, want ErrBackupUnsupported", err)
+	}
+}
@@ -0,0 +1,260 @@
+// Package dump reads and writes cache entries as JSONL or TSV so they can be moved between installs
+package dump
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+	"github.com/pkg/errors"
+)
+
+type Format int
+
+const (
+	JSONL Format = iota
+	TSV
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "jsonl", "json":
+		return JSONL, nil
+	case "tsv":
+		return TSV, nil
+	}
+
+	return JSONL, fmt.Errorf("unknown dump format %q", s)
+}
+
+// record is the on-disk form of an entry
+type record struct {
+	Service     string `json:"service"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Text        string `json:"text"`
+	Translation string `json:"translation"`
+	ErrorCode   string `json:"error_code"`
+	ErrorText   string `json:"error_text,omitempty"`
+	Timestamp   string `json:"timestamp"`
+	Raw         string `json:"raw,omitempty"`
+	PostProcess int    `json:"post_process,omitempty"`
+	Context     string `json:"context,omitempty"`
+	Manual      bool   `json:"manual,omitempty"`
+	Pinned      bool   `json:"pinned,omitempty"`
+	Hits        int64  `json:"hits,omitempty"`
+	LastAccess  string `json:"last_access,omitempty"`
+}
+
+var tsvHeader = []string{"service", "from", "to", "text", "translation", "error_code", "error_text", "timestamp"}
+
+func toRecord(e entry.Entry) record {
+	r := record{
+		Service:     e.Service,
+		From:        e.From,
+		To:          e.To,
+		Text:        e.Text,
+		Translation: e.Translation,
+		ErrorCode:   e.ErrorCode.String(),
+		ErrorText:   e.ErrorText,
+		Timestamp:   e.Timestamp.UTC().Format(time.RFC3339),
+		Raw:         e.Raw,
+		PostProcess: e.PostProcess,
+		Context:     e.Context,
+		Manual:      e.Manual,
+		Pinned:      e.Pinned,
+		Hits:        e.Hits,
+	}
+
+	if !e.LastAccess.IsZero() {
+		r.LastAccess = e.LastAccess.UTC().Format(time.RFC3339)
+	}
+
+	return r
+}
+
+func (r record) toEntry() (entry.Entry, error) {
+	var e entry.Entry
+
+	if r.Service == "" || r.Text == "" {
+		return e, fmt.Errorf("missing service or text")
+	}
+
+	code, err := entry.ParseErrorCode(r.ErrorCode)
+	if err != nil {
+		return e, err
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, r.Timestamp)
+	if err != nil {
+		return e, errors.Wrap(err, "invalid timestamp")
+	}
+
+	e = entry.Entry{
+		Key: entry.Key{
+			Service: r.Service,
+			From:    r.From,
+			To:      r.To,
+			Text:    r.Text,
+		},
+		Translation: r.Translation,
+		ErrorCode:   code,
+		ErrorText:   r.ErrorText,
+		Timestamp:   timestamp.UTC(),
+		Raw:         r.Raw,
+		PostProcess: r.PostProcess,
+		Context:     r.Context,
+		Manual:      r.Manual,
+		Pinned:      r.Pinned,
+		Hits:        r.Hits,
+	}
+
+	if r.LastAccess != "" {
+		if e.LastAccess, err = time.Parse(time.RFC3339, r.LastAccess); err != nil {
+			return e, errors.Wrap(err, "invalid last access time")
+		}
+	}
+
+	return e, nil
+}
+
+type Writer struct {
+	w      *bufio.Writer
+	format Format
+	header bool
+}
+
+func NewWriter(w io.Writer, format Format) *Writer {
+	return &Writer{
+		w:      bufio.NewWriter(w),
+		format: format,
+	}
+}
+
+func (w *Writer) Write(e entry.Entry) error {
+	r := toRecord(e)
+
+	if w.format == JSONL {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.w.Write(b); err != nil {
+			return err
+		}
+
+		return w.w.WriteByte('\n')
+	}
+
+	if !w.header {
+		w.header = true
+		if _, err := w.w.WriteString(strings.Join(tsvHeader, "\t") + "\n"); err != nil {
+			return err
+		}
+	}
+
+	fields := []string{r.Service, r.From, r.To, r.Text, r.Translation, r.ErrorCode, r.ErrorText, r.Timestamp}
+	for i := range fields {
+		fields[i] = escapeTSV(fields[i])
+	}
+
+	_, err := w.w.WriteString(strings.Join(fields, "\t") + "\n")
+
+	return err
+}
+
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+type Reader struct {
+	s      *bufio.Scanner
+	format Format
+	line   int
+}
+
+func NewReader(r io.Reader, format Format) *Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	return &Reader{
+		s:      s,
+		format: format,
+	}
+}
+
+// Read returns the next entry or io.EOF when there's nothing left
+func (r *Reader) Read() (entry.Entry, error) {
+	for r.s.Scan() {
+		r.line++
+
+		line := r.s.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var rec record
+
+		if r.format == JSONL {
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				return entry.Entry{}, errors.Wrapf(err, "line %d", r.line)
+			}
+		} else {
+			fields := strings.Split(line, "\t")
+			if r.line == 1 && fields[0] == tsvHeader[0] {
+				continue
+			}
+
+			if len(fields) != len(tsvHeader) {
+				return entry.Entry{}, fmt.Errorf("line %d: expected %d fields, got %d", r.line, len(tsvHeader), len(fields))
+			}
+
+			for i := range fields {
+				fields[i] = unescapeTSV(fields[i])
+			}
+
+			// TSV is meant for reading by people, raw output isn't included
+			rec = record{
+				Service:     fields[0],
+				From:        fields[1],
+				To:          fields[2],
+				Text:        fields[3],
+				Translation: fields[4],
+				ErrorCode:   fields[5],
+				ErrorText:   fields[6],
+				Timestamp:   fields[7],
+			}
+		}
+
+		e, err := rec.toEntry()
+		if err != nil {
+			return e, errors.Wrapf(err, "line %d", r.line)
+		}
+
+		return e, nil
+	}
+
+	if err := r.s.Err(); err != nil {
+		return entry.Entry{}, err
+	}
+
+	return entry.Entry{}, io.EOF
+}
+
+var (
+	tsvEscaper   = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+	tsvUnescaper = strings.NewReplacer("\\\\", "\\", "\\t", "\t", "\\n", "\n", "\\r", "\r")
+)
+
+func escapeTSV(s string) string {
+	return tsvEscaper.Replace(s)
+}
+
+func unescapeTSV(s string) string {
+	return tsvUnescaper.Replace(s)
+}
@@ -0,0 +1,127 @@
+package dump
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+)
+
+func Test_roundTrip(t *testing.T) {
+	entries := []entry.Entry{
+		{
+			Key:         entry.Key{Service: "Google", From: "ja", To: "en", Text: "これで俺の事"},
+			Translation: "With me this thing",
+			ErrorCode:   entry.ErrorNone,
+			Timestamp:   time.Date(2021, 8, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			Key:         entry.Key{Service: "Bing", From: "ja", To: "en", Text: "改行\tと\\タブ\n"},
+			Translation: "",
+			ErrorCode:   entry.ErrorBadTranslation,
+			ErrorText:   "garbage translation: \"a\" => \"b\"",
+			Timestamp:   time.Date(2021, 8, 2, 12, 0, 0, 0, time.UTC),
+		},
+	}
+
+	tests := []struct {
+		name   string
+		format Format
+	}{
+		{"jsonl", JSONL},
+		{"tsv", TSV},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			w := NewWriter(&buf, tt.format)
+			for _, e := range entries {
+				if err := w.Write(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			w.Flush()
+
+			var got []entry.Entry
+
+			r := NewReader(&buf, tt.format)
+			for {
+				e, err := r.Read()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+
+				got = append(got, e)
+			}
+
+			if !reflect.DeepEqual(got, entries) {
+				t.Errorf("round trip = %v, want %v", got, entries)
+			}
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+func TestWriter_WriteError(t *testing.T) {
+	// Bigger than write buffer so it has to be written out right away
+	e := entry.Entry{
+		Key:         entry.Key{Service: "Google", From: "ja", To: "en", Text: strings.Repeat("あ", 8192)},
+		Translation: "A",
+	}
+
+	for _, format := range []Format{JSONL, TSV} {
+		w := NewWriter(failingWriter{}, format)
+
+		err := w.Write(e)
+		if err == nil {
+			err = w.Flush()
+		}
+
+		if err == nil {
+			t.Errorf("format %v: expected write error", format)
+		}
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	e := entry.Entry{
+		Key:       entry.Key{Service: "Google", Text: "a"},
+		ErrorCode: entry.ErrorMinor,
+		Timestamp: time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty", Filter{}, true},
+		{"service", Filter{Service: "google"}, true},
+		{"other service", Filter{Service: "Bing"}, false},
+		{"since", Filter{Since: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)}, true},
+		{"since later", Filter{Since: time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)}, false},
+		{"until", Filter{Until: time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC)}, false},
+		{"only errors", Filter{Errors: OnlyErrors}, true},
+		{"no errors", Filter{Errors: NoErrors}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(e); got != tt.want {
+				t.Errorf("Filter.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package dump
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+)
+
+type ErrorFilter int
+
+const (
+	AllEntries ErrorFilter = iota
+	OnlyErrors
+	NoErrors
+)
+
+func ParseErrorFilter(s string) (ErrorFilter, error) {
+	switch strings.ToLower(s) {
+	case "", "all":
+		return AllEntries, nil
+	case "only":
+		return OnlyErrors, nil
+	case "none":
+		return NoErrors, nil
+	}
+
+	return AllEntries, fmt.Errorf("unknown error filter %q", s)
+}
+
+// Filter selects which entries get exported, zero value matches everything
+type Filter struct {
+	Service string
+	Since   time.Time
+	Until   time.Time
+	Errors  ErrorFilter
+}
+
+func (f Filter) Match(e entry.Entry) bool {
+	if f.Service != "" && !strings.EqualFold(f.Service, e.Service) {
+		return false
+	}
+
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+
+	if !f.Until.IsZero() && !e.Timestamp.Before(f.Until) {
+		return false
+	}
+
+	switch f.Errors {
+	case OnlyErrors:
+		return e.ErrorCode != entry.ErrorNone
+	case NoErrors:
+		return e.ErrorCode == entry.ErrorNone
+	}
+
+	return true
+}
+
+// ParseTime accepts either a date or a full RFC3339 timestamp
+func ParseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
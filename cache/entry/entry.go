@@ -0,0 +1,154 @@
+// Package entry holds the types shared between the cache and its storage backends
+package entry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type ErrorCode int
+
+const (
+	ErrorNone           ErrorCode = iota // Everything is fine
+	ErrorMinor                           // Something unexpected went wrong
+	ErrorBadTranslation                  // Returned really bad translation
+	ErrorRateLimited                     // Service asked us to slow down
+	ErrorAuthFailed                      // Api key is invalid or expired
+	ErrorUnsupported                     // Language pair isn't supported
+	ErrorTransient                       // Connection timed out or something like that
+	ErrorBlocked                         // Quota used up or service refused to work for us
+)
+
+var errorCodeNames = map[ErrorCode]string{
+	ErrorNone:           "none",
+	ErrorMinor:          "minor",
+	ErrorBadTranslation: "bad_translation",
+	ErrorRateLimited:    "rate_limited",
+	ErrorAuthFailed:     "auth_failed",
+	ErrorUnsupported:    "unsupported",
+	ErrorTransient:      "transient",
+	ErrorBlocked:        "blocked",
+}
+
+// ErrorCodes returns every known error code except ErrorNone
+func ErrorCodes() []ErrorCode {
+	var codes []ErrorCode
+
+	for code := range errorCodeNames {
+		if code != ErrorNone {
+			codes = append(codes, code)
+		}
+	}
+
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+
+	return codes
+}
+
+func (c ErrorCode) String() string {
+	if name, ok := errorCodeNames[c]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("error_%d", int(c))
+}
+
+// ParseErrorCode accepts both the names returned by String and plain numbers
+func ParseErrorCode(s string) (ErrorCode, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	for code, name := range errorCodeNames {
+		if s == name {
+			return code, nil
+		}
+	}
+
+	var code int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(s, "error_"), "%d", &code); err != nil {
+		return ErrorNone, fmt.Errorf("unknown error code %q", s)
+	}
+
+	return ErrorCode(code), nil
+}
+
+// Key uniquely identifies a cached translation, fields are in storage sort order
+type Key struct {
+	Service string
+	From    string
+	To      string
+	Text    string
+}
+
+// Less reports whether k sorts before o
+func (k Key) Less(o Key) bool {
+	if k.Service != o.Service {
+		return k.Service < o.Service
+	}
+	if k.From != o.From {
+		return k.From < o.From
+	}
+	if k.To != o.To {
+		return k.To < o.To
+	}
+
+	return k.Text < o.Text
+}
+
+// Entry is a single row of the translation cache
+type Entry struct {
+	Key
+
+	Translation string
+	ErrorCode   ErrorCode
+	ErrorText   string
+	Timestamp   time.Time
+
+	// Raw is translation as engine returned it, before PostProcess version of its cleanup was applied
+	Raw         string
+	PostProcess int
+
+	// Context holds JSON encoded previous lines of conversation translation was made with, empty for most engines
+	Context string
+
+	// Manual entries were edited by hand, they are never evicted and neither are pinned ones
+	Manual bool
+	Pinned bool
+
+	// Hits counts how many times entry was read, LastAccess is when it last happened
+	Hits       int64
+	LastAccess time.Time
+}
+
+// Hits are reads of an entry collected since last time they were stored
+type Hits struct {
+	Count int64
+	Last  time.Time
+}
+
+// Kept reports whether entry is exempt from eviction and expiry of successful translations
+func (e Entry) Kept() bool {
+	return e.Manual || e.Pinned
+}
+
+type MergeStrategy int
+
+const (
+	KeepExisting MergeStrategy = iota // Only insert entries that aren't cached yet
+	Overwrite                         // Replace whatever is cached
+	NewestWins                        // Replace cached entries that are older
+)
+
+func ParseMergeStrategy(s string) (MergeStrategy, error) {
+	switch strings.ToLower(s) {
+	case "keep", "keep-existing":
+		return KeepExisting, nil
+	case "overwrite":
+		return Overwrite, nil
+	case "newest", "newest-wins":
+		return NewestWins, nil
+	}
+
+	return KeepExisting, fmt.Errorf("unknown merge strategy %q", s)
+}
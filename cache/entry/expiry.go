@@ -0,0 +1,50 @@
+package entry
+
+import "time"
+
+// Never keeps entries forever when used as expiration time
+const Never time.Duration = -1
+
+// ExpiryPolicy decides how long entries are kept for, per service values take precedence over defaults
+type ExpiryPolicy struct {
+	Default  map[ErrorCode]time.Duration
+	Services map[string]map[ErrorCode]time.Duration
+}
+
+// TTL returns how long entry with given error code from service should be kept
+func (p ExpiryPolicy) TTL(service string, code ErrorCode) time.Duration {
+	if d, ok := p.Services[service][code]; ok {
+		return d
+	}
+
+	if d, ok := p.Default[code]; ok {
+		return d
+	}
+
+	// Unknown error, treat it as a minor one
+	if d, ok := p.Default[ErrorMinor]; ok {
+		return d
+	}
+
+	return time.Hour / 2
+}
+
+// Expired reports whether entry stored at given time should be thrown away
+func (p ExpiryPolicy) Expired(service string, code ErrorCode, stored time.Time) bool {
+	ttl := p.TTL(service, code)
+	if ttl == Never {
+		return false
+	}
+
+	return time.Since(stored) > ttl
+}
+
+// Cutoff returns time before which entries should be thrown away, false if they are kept forever
+func (p ExpiryPolicy) Cutoff(service string, code ErrorCode, now time.Time) (time.Time, bool) {
+	ttl := p.TTL(service, code)
+	if ttl == Never {
+		return time.Time{}, false
+	}
+
+	return now.Add(-ttl), true
+}
@@ -0,0 +1,35 @@
+package entry
+
+import (
+	"fmt"
+	"time"
+)
+
+// Reason tells why stored translation was replaced
+type Reason string
+
+const (
+	ReasonRefresh  Reason = "refresh"  // Translated again after old one expired or failed
+	ReasonReport   Reason = "report"   // Reported as a bad translation
+	ReasonManual   Reason = "manual"   // Edited by hand
+	ReasonRollback Reason = "rollback" // Older version was restored
+	ReasonImport   Reason = "import"   // Overwritten in bulk by import or reprocess command
+)
+
+func ParseReason(s string) (Reason, error) {
+	switch r := Reason(s); r {
+	case ReasonRefresh, ReasonReport, ReasonManual, ReasonRollback, ReasonImport:
+		return r, nil
+	}
+
+	return "", fmt.Errorf("unknown reason %q", s)
+}
+
+// Version is a translation that used to be stored for a key before it got replaced
+type Version struct {
+	Entry
+
+	ID       int64
+	Reason   Reason
+	Replaced time.Time
+}
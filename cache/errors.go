@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// BackendError is returned when storage backend fails, memory cache keeps working in the meantime
+type BackendError struct {
+	Op  string
+	Err error
+}
+
+func (e *BackendError) Error() string {
+	return fmt.Sprintf("cache %s failed: %v", e.Op, e.Err)
+}
+
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// IsBackendError reports whether err came from storage backend rather than being a cached translation error
+func IsBackendError(err error) bool {
+	var be *BackendError
+
+	return errors.As(err, &be)
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"gitgud.io/softashell/comfy-translator/config"
+)
+
+// MaintenanceReport describes what a single maintenance run did
+type MaintenanceReport struct {
+	Purged       int64  `json:"purged"`
+	Evicted      int64  `json:"evicted"`
+	Checkpointed bool   `json:"checkpointed"`
+	FreedPages   int64  `json:"freedPages"`
+	Took         string `json:"took"`
+}
+
+// Maintenance periodically cleans up the cache in background
+type Maintenance struct {
+	c Cache
+
+	interval    time.Duration
+	checkpoint  bool
+	vacuumPages int
+	maxRows     int64
+	maxBytes    int64
+
+	lock *sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewMaintenance(c Cache, conf *config.Config) *Maintenance {
+	return &Maintenance{
+		c:           c,
+		interval:    conf.Database.Maintenance.Interval.Duration(),
+		checkpoint:  conf.Database.Maintenance.Checkpoint,
+		vacuumPages: conf.Database.Maintenance.VacuumPages,
+		maxRows:     conf.Database.Eviction.MaxRows,
+		maxBytes:    conf.Database.Eviction.MaxSizeMB * 1024 * 1024,
+		lock:        &sync.Mutex{},
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start runs maintenance on configured interval until Stop is called
+func (m *Maintenance) Start() {
+	if m.interval <= 0 {
+		log.Info("Cache maintenance disabled")
+		close(m.done)
+		return
+	}
+
+	log.Infof("Running cache maintenance every %s", m.interval)
+
+	go func() {
+		defer close(m.done)
+
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := m.Run(); err != nil {
+					log.Errorf("Cache maintenance failed: %v", err)
+				}
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop waits for running maintenance to finish and stops the scheduler
+func (m *Maintenance) Stop() {
+	close(m.stop)
+	<-m.done
+}
+
+// Run does maintenance right now, concurrent calls wait for each other
+func (m *Maintenance) Run() (MaintenanceReport, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var report MaintenanceReport
+	var err error
+
+	start := time.Now()
+
+	report.Purged, err = m.c.PurgeExpired()
+	if err != nil {
+		return report, err
+	}
+
+	// Evict before vacuum so freed pages are reclaimed in the same run
+	report.Evicted, err = m.c.Evict(m.maxRows, m.maxBytes)
+	if err != nil {
+		return report, err
+	}
+
+	if compactor, ok := backend(m.c).(Compactor); ok {
+		if m.checkpoint {
+			if err := compactor.Checkpoint(); err != nil {
+				return report, err
+			}
+
+			report.Checkpointed = true
+		}
+
+		if m.vacuumPages >= 0 {
+			report.FreedPages, err = compactor.IncrementalVacuum(m.vacuumPages)
+			if err != nil {
+				return report, err
+			}
+		}
+	}
+
+	report.Took = time.Since(start).String()
+
+	log.WithFields(log.Fields{
+		"time":         report.Took,
+		"purged":       report.Purged,
+		"evicted":      report.Evicted,
+		"checkpointed": report.Checkpointed,
+		"freedPages":   report.FreedPages,
+	}).Info("Finished cache maintenance")
+
+	return report, nil
+}
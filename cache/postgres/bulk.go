@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Scan returns up to limit entries sorted by key that come after the given key
+func (c *Cache) Scan(after entry.Key, limit int) ([]entry.Entry, error) {
+	var rows []Translation
+
+	result := c.db.
+		Where("(service, from_lang, to_lang, text) > (?, ?, ?, ?)", after.Service, after.From, after.To, after.Text).
+		Order("service, from_lang, to_lang, text").
+		Limit(limit).
+		Find(&rows)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "failed to scan translations")
+	}
+
+	out := make([]entry.Entry, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, r.toEntry())
+	}
+
+	return out, nil
+}
+
+func (c *Cache) Count() (int64, error) {
+	var count int64
+
+	if result := c.db.Model(&Translation{}).Count(&count); result.Error != nil {
+		return 0, errors.Wrap(result.Error, "failed to count translations")
+	}
+
+	return count, nil
+}
+
+// Import stores entries in a single transaction and returns how many rows were changed
+func (c *Cache) Import(entries []entry.Entry, strategy entry.MergeStrategy) (int, error) {
+	if len(entries) < 1 {
+		return 0, nil
+	}
+
+	onConflict := clause.OnConflict{
+		Columns: []clause.Column{{Name: "service"}, {Name: "from_lang"}, {Name: "to_lang"}, {Name: "text"}},
+	}
+
+	switch strategy {
+	case entry.KeepExisting:
+		onConflict.DoNothing = true
+	case entry.Overwrite:
+		onConflict.UpdateAll = true
+	case entry.NewestWins:
+		onConflict.DoUpdates = clause.AssignmentColumns([]string{"translation", "error_code", "error_text", "timestamp", "raw", "post_process", "context", "manual", "pinned", "hits", "last_access"})
+		onConflict.Where = clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "translations.timestamp < excluded.timestamp"},
+		}}
+	}
+
+	rows := make([]Translation, 0, len(entries))
+	for _, e := range entries {
+		rows = append(rows, fromEntry(e))
+	}
+
+	var changed int64
+
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		// Overwritten translations go to history just like replaced ones, newest wins only replaces older ones
+		now := time.Now().UTC()
+
+		for _, e := range entries {
+			var err error
+
+			switch strategy {
+			case entry.Overwrite:
+				err = archive(tx, e, entry.ReasonImport, now)
+			case entry.NewestWins:
+				err = archiveOlder(tx, e, entry.ReasonImport, now)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		result := tx.Clauses(onConflict).Create(&rows)
+		changed = result.RowsAffected
+
+		return result.Error
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to import translations")
+	}
+
+	return int(changed), nil
+}
+
+func (t Translation) toEntry() entry.Entry {
+	return entry.Entry{
+		Key: entry.Key{
+			Service: t.Service,
+			From:    t.FromLang,
+			To:      t.ToLang,
+			Text:    t.Text,
+		},
+		Translation: t.Translation,
+		ErrorCode:   t.ErrorCode,
+		ErrorText:   t.ErrorText,
+		Timestamp:   t.Timestamp.UTC(),
+		Raw:         t.Raw,
+		PostProcess: t.PostProcess,
+		Context:     t.Context,
+		Manual:      t.Manual,
+		Pinned:      t.Pinned,
+		Hits:        t.Hits,
+		LastAccess:  t.LastAccess.UTC(),
+	}
+}
+
+func fromEntry(e entry.Entry) Translation {
+	t := Translation{
+		Service:     e.Service,
+		FromLang:    e.From,
+		ToLang:      e.To,
+		Text:        e.Text,
+		Translation: e.Translation,
+		ErrorCode:   e.ErrorCode,
+		ErrorText:   e.ErrorText,
+		Timestamp:   e.Timestamp.UTC(),
+		Raw:         e.Raw,
+		PostProcess: e.PostProcess,
+		Context:     e.Context,
+		Manual:      e.Manual,
+		Pinned:      e.Pinned,
+		Hits:        e.Hits,
+		LastAccess:  e.LastAccess.UTC(),
+	}
+
+	// Never read entries count as accessed when they were stored
+	if e.LastAccess.IsZero() {
+		t.LastAccess = t.Timestamp
+	}
+
+	return t
+}
@@ -1,11 +1,9 @@
 package postgres
 
 import (
-	"fmt"
 	"time"
 
-	"gitgud.io/softashell/comfy-translator/translator"
-	lru "github.com/hashicorp/golang-lru"
+	"gitgud.io/softashell/comfy-translator/cache/entry"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -15,182 +13,176 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-type translationError int
-
-const (
-	errorNone           translationError = iota // Everything is fine
-	errorMinor                                  // Connection timed out or something like that
-	errorBadTranslation                         // Returned really bad translation
-)
-
 type Cache struct {
-	db       *gorm.DB
-	lrustore map[string]*lru.TwoQueueCache
-}
-
-type Item struct {
-	Translation string
-	ErrorCode   translationError
-	ErrorText   string
-	Timestamp   int64
+	db *gorm.DB
 }
 
 type Translation struct {
-	Service string `gorm:"primaryKey"`
-	Text string `gorm:"primarykey"`
+	Service     string `gorm:"primaryKey"`
+	FromLang    string `gorm:"primaryKey;default:ja"`
+	ToLang      string `gorm:"primaryKey;default:en"`
+	Text        string `gorm:"primaryKey"`
 	Translation string
-	ErrorCode   translationError
+	ErrorCode   entry.ErrorCode
 	ErrorText   string
 	Timestamp   time.Time
+	Raw         string
+	PostProcess int
+	Context     string
+	Manual      bool
+	Pinned      bool
+	Hits        int64
+	LastAccess  time.Time `gorm:"index:idx_translations_eviction,where:error_code = 0 AND NOT manual AND NOT pinned"`
 }
 
-func NewCache(connStr string, cacheSize int, translators []string) (*Cache, error) {
+func NewCache(connStr string) (*Cache, error) {
 	newLogger := logger.New(log.StandardLogger(),
-	logger.Config{
-		SlowThreshold:              time.Second/2,   // Slow SQL threshold
-		LogLevel:                   logger.Warn, 	 // Log level
-		IgnoreRecordNotFoundError: 	true,            // Ignore ErrRecordNotFound error for logger
-		Colorful:                  	true,            // Disable color
-	})
+		logger.Config{
+			SlowThreshold:             time.Second / 2, // Slow SQL threshold
+			LogLevel:                  logger.Warn,     // Log level
+			IgnoreRecordNotFoundError: true,            // Ignore ErrRecordNotFound error for logger
+			Colorful:                  true,            // Disable color
+		})
 
 	db, err := gorm.Open(postgres.Open(connStr), &gorm.Config{
 		Logger: newLogger,
 	})
 	if err != nil {
-		log.Fatal(err)
-		return nil, err
+		return nil, errors.Wrap(err, "failed to open database")
 	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatal(err)
-		return nil, err
+		return nil, errors.Wrap(err, "failed to open database")
 	}
 
 	sqlDB.SetMaxIdleConns(10)
 	sqlDB.SetMaxOpenConns(10)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	err = db.AutoMigrate(&Translation{})
+	// Tables created before language pairs were stored need their primary key replaced
+	migratePair := db.Migrator().HasTable(&Translation{}) && !db.Migrator().HasColumn(&Translation{}, "FromLang")
+	migrateAccess := db.Migrator().HasTable(&Translation{}) && !db.Migrator().HasColumn(&Translation{}, "LastAccess")
+
+	err = db.AutoMigrate(&Translation{}, &TranslationVersion{})
 	if err != nil {
-		log.Fatal(err)
-		return nil, err
+		return nil, errors.Wrap(err, "failed to migrate database")
 	}
 
-	store := make(map[string]*lru.TwoQueueCache)
-	for _, t := range translators {
-		s, err := lru.New2Q(5000)
-		if err != nil {
-			log.Fatalf("Can't start memory cache for %s", t)
+	if migratePair {
+		log.Info("Adding language pair to translations primary key")
+
+		result := db.Exec("ALTER TABLE translations DROP CONSTRAINT translations_pkey, ADD PRIMARY KEY (service, from_lang, to_lang, text)")
+		if result.Error != nil {
+			return nil, errors.Wrap(result.Error, "failed to update primary key")
 		}
+	}
+
+	if migrateAccess {
+		log.Info("Setting last access time of existing translations")
 
-		store[t] = s
+		result := db.Exec("UPDATE translations SET last_access = timestamp")
+		if result.Error != nil {
+			return nil, errors.Wrap(result.Error, "failed to set last access time")
+		}
 	}
 
-	cache := &Cache{db: db, lrustore: store}
+	cache := &Cache{db: db}
 
 	return cache, nil
 }
 
 func (c *Cache) Close() error {
 	sqlDB, err := c.db.DB()
-	if(err != nil) {
+	if err != nil {
 		return err
 	}
-	
+
 	return sqlDB.Close()
 }
 
-func (c *Cache) Put(bucketName, text, translation string, cerr error) error {
-	errorCode := errorNone
-	errorText := ""
+func (c *Cache) Save(e entry.Entry) error {
+	return c.Replace(e, entry.ReasonRefresh)
+}
 
-	if cerr != nil {
-		errorText = cerr.Error()
+func (c *Cache) SaveBatch(entries []entry.Entry) error {
+	return c.replace(entries, entry.ReasonRefresh)
+}
 
-		switch cerr.(type) {
-		case translator.BadTranslationError:
-			errorCode = errorBadTranslation
-		default:
-			errorCode = errorMinor
-		}
-	}
+// Replace stores entry, translation it replaces is kept in history
+func (c *Cache) Replace(e entry.Entry, reason entry.Reason) error {
+	return c.replace([]entry.Entry{e}, reason)
+}
 
-	pgItem := Translation{
-		Text: 			text,	
-		Service: 		bucketName,
-		Translation: 	translation,
-		ErrorCode:   	errorCode,
-		ErrorText:   	errorText,
-		Timestamp:   	time.Now().UTC(),
+func (c *Cache) replace(entries []entry.Entry, reason entry.Reason) error {
+	if len(entries) == 0 {
+		return nil
 	}
 
-	result := c.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&pgItem)
-	if result.Error != nil {
-		log.Fatal(errors.Wrap(result.Error, "failed to execute insert"))
-	}
+	now := time.Now().UTC()
 
-	// Add to memory cache
-	i := Item{
-		Translation: translation,
-		ErrorCode:   errorCode,
-		ErrorText:   errorText,
-		Timestamp:   time.Now().UTC().Unix(),
-	}
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		items := make([]Translation, len(entries))
 
-	c.lrustore[bucketName].Add(text, i)
+		for i, e := range entries {
+			if err := archive(tx, e, reason, now); err != nil {
+				return err
+			}
 
-	return result.Error
-}
+			items[i] = fromEntry(e)
+		}
 
-func (c *Cache) Get(bucketName, text string) (string, bool, error) {
-	var found bool
-	var translation string
-	var errorCode translationError
-	var errorText string
-	var timestamp int64
-
-	item, ok := c.lrustore[bucketName].Get(text)
-	if ok {
-		i := item.(Item)
-
-		translation = i.Translation
-		errorCode = i.ErrorCode
-		errorText = i.ErrorText
-	} else {
-		i := Translation{}
-		result := c.db.Limit(1).Find(&i, Translation{Text: text,	Service: bucketName})
+		// Read statistics and pin survive translation being replaced
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "service"}, {Name: "from_lang"}, {Name: "to_lang"}, {Name: "text"}},
+			DoUpdates: clause.AssignmentColumns([]string{"translation", "error_code", "error_text", "timestamp", "raw", "post_process", "context", "manual"}),
+		}).Create(&items)
 		if result.Error != nil {
-			return translation, found, nil
+			return errors.Wrap(result.Error, "failed to execute insert")
 		}
 
-		translation = i.Translation
-		errorCode = i.ErrorCode
-		errorText = i.ErrorText
+		return nil
+	})
+}
+
+// keyCondition matches a single entry, struct conditions would skip empty fields like text or language and match others
+const keyCondition = "service = ? AND from_lang = ? AND to_lang = ? AND text = ?"
+
+func (c *Cache) Load(key entry.Key) (entry.Entry, bool, error) {
+	i := Translation{}
+
+	result := c.db.Where(keyCondition, key.Service, key.From, key.To, key.Text).Limit(1).Find(&i)
+	if result.Error != nil {
+		return entry.Entry{Key: key}, false, errors.Wrap(result.Error, "failed to execute select")
 	}
 
-	if translation != "" {
-		found = true
+	if result.RowsAffected == 0 {
+		return entry.Entry{Key: key}, false, nil
 	}
 
-	if errorCode != errorNone {
-		errorTime := time.Unix(timestamp, 0)
+	return i.toEntry(), true, nil
+}
 
-		if time.Since(errorTime) > getCacheExpiration(errorCode) {
-			i := Translation{}
-			result := c.db.Delete(&i, Translation{Text: text,	Service: bucketName})
-			if result.Error != nil {
-				log.Warn("unable to delete item: ", result.Error)
-			}
+func (c *Cache) LoadAll(services []string, from, to, text string) ([]entry.Entry, error) {
+	if len(services) == 0 {
+		return nil, nil
+	}
 
-			c.lrustore[bucketName].Remove(text)
+	var items []Translation
 
-			// Act as if nothing was found
-			return "", false, nil
-		}
+	result := c.db.Where("service IN ? AND from_lang = ? AND to_lang = ? AND text = ?", services, from, to, text).Find(&items)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "failed to execute select")
+	}
 
-		return "", found, fmt.Errorf("%s", errorText)
+	entries := make([]entry.Entry, len(items))
+	for i, item := range items {
+		entries[i] = item.toEntry()
 	}
 
-	return translation, found, nil
+	return entries, nil
+}
+
+func (c *Cache) Delete(key entry.Key) error {
+	return c.db.Where(keyCondition, key.Service, key.From, key.To, key.Text).Delete(&Translation{}).Error
 }
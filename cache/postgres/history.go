@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+)
+
+// TranslationVersion is a translation that got replaced
+type TranslationVersion struct {
+	ID          int64  `gorm:"primaryKey"`
+	Service     string `gorm:"index:idx_translation_versions_key"`
+	FromLang    string `gorm:"index:idx_translation_versions_key"`
+	ToLang      string `gorm:"index:idx_translation_versions_key"`
+	Text        string `gorm:"index:idx_translation_versions_key"`
+	Translation string
+	ErrorCode   entry.ErrorCode
+	ErrorText   string
+	Timestamp   time.Time
+	Raw         string
+	PostProcess int
+	Context     string
+	Reason      entry.Reason
+	Replaced    time.Time
+}
+
+// archive copies currently stored translation to history, only successful translations that are about to change are kept
+func archive(tx *gorm.DB, e entry.Entry, reason entry.Reason, now time.Time) error {
+	return archiveWhere(tx, archiveQuery, e, reason, now)
+}
+
+// archiveOlder only archives stored translation that's older than e, for imports where newest one wins
+func archiveOlder(tx *gorm.DB, e entry.Entry, reason entry.Reason, now time.Time) error {
+	return archiveWhere(tx, archiveQuery+" AND timestamp < ?", e, reason, now, e.Timestamp.UTC())
+}
+
+const archiveQuery = `INSERT INTO translation_versions (service, from_lang, to_lang, text, translation, error_code, error_text, timestamp, raw, post_process, context, reason, replaced)
+	SELECT service, from_lang, to_lang, text, translation, error_code, error_text, timestamp, raw, post_process, context, ?, ? FROM translations
+	WHERE service = ? AND from_lang = ? AND to_lang = ? AND text = ? AND error_code = 0 AND translation != ?`
+
+func archiveWhere(tx *gorm.DB, query string, e entry.Entry, reason entry.Reason, now time.Time, extra ...interface{}) error {
+	args := append([]interface{}{reason, now, e.Service, e.From, e.To, e.Text, e.Translation}, extra...)
+
+	result := tx.Exec(query, args...)
+	if result.Error != nil {
+		return errors.Wrapf(result.Error, "failed to archive %q", e.Text)
+	}
+
+	return nil
+}
+
+// History returns translations that were stored for key before, newest first
+func (c *Cache) History(key entry.Key) ([]entry.Version, error) {
+	var items []TranslationVersion
+
+	result := c.db.Where(keyCondition, key.Service, key.From, key.To, key.Text).Order("id DESC").Find(&items)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "failed to select history")
+	}
+
+	versions := make([]entry.Version, len(items))
+	for i, item := range items {
+		versions[i] = item.toVersion()
+	}
+
+	return versions, nil
+}
+
+// Version returns a single version from history
+func (c *Cache) Version(key entry.Key, id int64) (entry.Version, bool, error) {
+	var item TranslationVersion
+
+	result := c.db.Where("id = ? AND "+keyCondition, id, key.Service, key.From, key.To, key.Text).Limit(1).Find(&item)
+	if result.Error != nil {
+		return entry.Version{}, false, errors.Wrap(result.Error, "failed to select history")
+	}
+
+	if result.RowsAffected == 0 {
+		return entry.Version{}, false, nil
+	}
+
+	return item.toVersion(), true, nil
+}
+
+func (i TranslationVersion) toVersion() entry.Version {
+	return entry.Version{
+		Entry: entry.Entry{
+			Key:         entry.Key{Service: i.Service, From: i.FromLang, To: i.ToLang, Text: i.Text},
+			Translation: i.Translation,
+			ErrorCode:   i.ErrorCode,
+			ErrorText:   i.ErrorText,
+			Timestamp:   i.Timestamp.UTC(),
+			Raw:         i.Raw,
+			PostProcess: i.PostProcess,
+			Context:     i.Context,
+		},
+		ID:       i.ID,
+		Reason:   i.Reason,
+		Replaced: i.Replaced.UTC(),
+	}
+}
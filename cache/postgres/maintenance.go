@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+	"github.com/pkg/errors"
+)
+
+// DeleteOlder deletes entries from service with given error code stored before given time
+func (c *Cache) DeleteOlder(service string, code entry.ErrorCode, before time.Time) (int64, error) {
+	result := c.db.Where("service = ? AND error_code = ? AND timestamp < ?", service, code, before.UTC()).Delete(&Translation{})
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "failed to delete expired entries")
+	}
+
+	return result.RowsAffected, nil
+}
+
+// Services lists every service that has something stored
+func (c *Cache) Services() ([]string, error) {
+	var services []string
+
+	if result := c.db.Model(&Translation{}).Distinct().Pluck("service", &services); result.Error != nil {
+		return nil, errors.Wrap(result.Error, "failed to list services")
+	}
+
+	return services, nil
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+)
+
+// RecordHits adds read counts and moves last access time forward
+func (c *Cache) RecordHits(hits map[entry.Key]entry.Hits) error {
+	if len(hits) == 0 {
+		return nil
+	}
+
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		for key, h := range hits {
+			result := tx.Exec("UPDATE translations SET hits = hits + ?, last_access = GREATEST(last_access, ?) WHERE service = ? AND from_lang = ? AND to_lang = ? AND text = ?",
+				h.Count, h.Last.UTC(), key.Service, key.From, key.To, key.Text)
+			if result.Error != nil {
+				return errors.Wrap(result.Error, "failed to record hits")
+			}
+		}
+
+		return nil
+	})
+}
+
+// SetPinned changes whether entry is exempt from eviction, found is false when there's no such entry
+func (c *Cache) SetPinned(key entry.Key, pinned bool) (bool, error) {
+	result := c.db.Model(&Translation{}).
+		Where(keyCondition, key.Service, key.From, key.To, key.Text).
+		Update("pinned", pinned)
+	if result.Error != nil {
+		return false, errors.Wrap(result.Error, "failed to update pin")
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// Evict deletes up to n successful translations that weren't read for longest, manual and pinned ones are kept
+func (c *Cache) Evict(n int64) (int64, error) {
+	result := c.db.Exec(`DELETE FROM translations WHERE ctid IN (
+		SELECT ctid FROM translations WHERE error_code = 0 AND NOT manual AND NOT pinned ORDER BY last_access LIMIT ?
+		)`, n)
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "failed to evict translations")
+	}
+
+	return result.RowsAffected, nil
+}
+
+// Hot returns up to limit successful translations of service that were read most often
+func (c *Cache) Hot(service string, limit int) ([]entry.Entry, error) {
+	var items []Translation
+
+	result := c.db.Where("service = ? AND error_code = 0", service).Order("hits DESC, last_access DESC").Limit(limit).Find(&items)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "failed to select hot translations")
+	}
+
+	entries := make([]entry.Entry, len(items))
+	for i, item := range items {
+		entries[i] = item.toEntry()
+	}
+
+	return entries, nil
+}
+
+// Size returns bytes used by translations table including its indexes
+func (c *Cache) Size() (int64, error) {
+	var size int64
+
+	if err := c.db.Raw("SELECT pg_total_relation_size('translations')").Scan(&size).Error; err != nil {
+		return 0, errors.Wrap(err, "failed to get table size")
+	}
+
+	return size, nil
+}
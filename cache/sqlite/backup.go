@@ -0,0 +1,24 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// Backup writes a consistent copy of the database to path without blocking writers, path must not exist yet
+func (c *Cache) Backup(path string) error {
+	// Read only connections can't write the copy and writer would hold up every write until it's done,
+	// separate connection reads a single snapshot while writes keep going to the WAL
+	db, err := sql.Open("sqlite3", c.path+"?_busy_timeout=5000")
+	if err != nil {
+		return errors.Wrap(err, "failed to open database for backup")
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("VACUUM INTO ?", path); err != nil {
+		return errors.Wrapf(err, "failed to write backup to %s", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+	"github.com/pkg/errors"
+)
+
+// Scan returns up to limit entries sorted by key that come after the given key
+func (c *Cache) Scan(after entry.Key, limit int) ([]entry.Entry, error) {
+	rows, err := c.reader.Query(`SELECT service, fromLang, toLang, text, translation, errorCode, errorText, time, raw, postProcess, context, manual, pinned, hits, lastAccess FROM Translations
+		WHERE (service, fromLang, toLang, text) > (?, ?, ?, ?)
+		ORDER BY service, fromLang, toLang, text
+		LIMIT ?`, after.Service, after.From, after.To, after.Text, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to scan translations")
+	}
+	defer rows.Close()
+
+	var out []entry.Entry
+
+	for rows.Next() {
+		var e entry.Entry
+		var timestamp, lastAccess int64
+
+		if err := rows.Scan(&e.Service, &e.From, &e.To, &e.Text, &e.Translation, &e.ErrorCode, &e.ErrorText, &timestamp, &e.Raw, &e.PostProcess, &e.Context,
+			&e.Manual, &e.Pinned, &e.Hits, &lastAccess); err != nil {
+			return nil, errors.Wrap(err, "failed to read translation")
+		}
+
+		e.Timestamp = time.Unix(timestamp, 0).UTC()
+		e.LastAccess = time.Unix(lastAccess, 0).UTC()
+
+		out = append(out, e)
+	}
+
+	return out, rows.Err()
+}
+
+// Import stores entries in a single transaction and returns how many rows were changed
+func (c *Cache) Import(entries []entry.Entry, strategy entry.MergeStrategy) (int, error) {
+	var query string
+
+	switch strategy {
+	case entry.KeepExisting:
+		query = "INSERT OR IGNORE INTO Translations(text, service, fromLang, toLang, translation, errorCode, errorText, time, raw, postProcess, context, manual, pinned, hits, lastAccess) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	case entry.Overwrite:
+		query = "INSERT OR REPLACE INTO Translations(text, service, fromLang, toLang, translation, errorCode, errorText, time, raw, postProcess, context, manual, pinned, hits, lastAccess) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	case entry.NewestWins:
+		query = `INSERT INTO Translations(text, service, fromLang, toLang, translation, errorCode, errorText, time, raw, postProcess, context, manual, pinned, hits, lastAccess) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(service, fromLang, toLang, text) DO UPDATE SET
+			translation = excluded.translation, errorCode = excluded.errorCode, errorText = excluded.errorText, time = excluded.time,
+			raw = excluded.raw, postProcess = excluded.postProcess, context = excluded.context, manual = excluded.manual, pinned = excluded.pinned,
+			hits = excluded.hits, lastAccess = excluded.lastAccess
+			WHERE excluded.time > Translations.time`
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to start transaction")
+	}
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "failed to prepare insert")
+	}
+	defer stmt.Close()
+
+	// Overwritten translations go to history just like replaced ones, newest wins only replaces older ones
+	var archive *sql.Stmt
+
+	switch strategy {
+	case entry.Overwrite:
+		archive = tx.Stmt(c.archive)
+	case entry.NewestWins:
+		archive, err = tx.Prepare(archiveQuery + " AND time < ?")
+		if err != nil {
+			tx.Rollback()
+			return 0, errors.Wrap(err, "failed to prepare archive")
+		}
+	}
+
+	if archive != nil {
+		defer archive.Close()
+	}
+
+	now := time.Now().UTC().Unix()
+
+	var changed int
+
+	for _, e := range entries {
+		if archive != nil {
+			args := []interface{}{entry.ReasonImport, now, e.Service, e.From, e.To, e.Text, e.Translation}
+			if strategy == entry.NewestWins {
+				args = append(args, e.Timestamp.UTC().Unix())
+			}
+
+			if _, err := archive.Exec(args...); err != nil {
+				tx.Rollback()
+				return 0, errors.Wrapf(err, "failed to archive %q", e.Text)
+			}
+		}
+
+		res, err := stmt.Exec(e.Text, e.Service, e.From, e.To, e.Translation, e.ErrorCode, e.ErrorText, e.Timestamp.UTC().Unix(), e.Raw, e.PostProcess, e.Context,
+			e.Manual, e.Pinned, e.Hits, lastAccess(e))
+		if err != nil {
+			tx.Rollback()
+			return 0, errors.Wrapf(err, "failed to import %q", e.Text)
+		}
+
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			changed++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "failed to commit import")
+	}
+
+	return changed, nil
+}
+
+func (c *Cache) Count() (int64, error) {
+	var count int64
+
+	if err := c.reader.QueryRow("SELECT COUNT(*) FROM Translations").Scan(&count); err != nil {
+		return 0, errors.Wrap(err, "failed to count translations")
+	}
+
+	return count, nil
+}
@@ -3,172 +3,387 @@ package sqlite
 import (
 	"database/sql"
 	"fmt"
+	"runtime"
+	"strings"
+	"sync"
 	"time"
 
-	"gitgud.io/softashell/comfy-translator/translator"
-	lru "github.com/hashicorp/golang-lru"
+	"gitgud.io/softashell/comfy-translator/cache/entry"
 	_ "github.com/mattn/go-sqlite3" // Sql driver
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
-type translationError int
+// Writes queued while a batch is open are committed together, but never more than this many at once
+const maxBatchSize = 500
 
-const (
-	errorNone           translationError = iota // Everything is fine
-	errorMinor                                  // Connection timed out or something like that
-	errorBadTranslation                         // Returned really bad translation
-)
-
-const (
-	cleanupInterval = (24 * time.Hour) * 7
-)
+type Options struct {
+	// Page cache size in kib, writer gets all of it and readers split it between themselves
+	CacheSize int
+	// Number of read only connections, defaults to number of CPUs
+	Readers int
+	// How long writer waits for more writes before committing them in one transaction, 0 commits each write on its own
+	BatchWindow time.Duration
+}
 
+// Cache keeps a single writer connection and a pool of read only connections,
+// WAL lets readers carry on while something is being written
 type Cache struct {
-	db       *sql.DB
-	lrustore map[string]*lru.TwoQueueCache
+	path   string
+	db     *sql.DB // Writer, only ever has one connection open
+	reader *sql.DB
+
+	load    *sql.Stmt
+	save    *sql.Stmt
+	archive *sql.Stmt
+	delete  *sql.Stmt
+
+	// LoadAll statements by number of services
+	loadAll     map[int]*sql.Stmt
+	loadAllLock *sync.Mutex
+
+	writes     chan writeRequest
+	writerDone chan struct{}
+	closeLock  *sync.RWMutex
+	closed     bool
 }
 
-type Item struct {
-	Translation string
-	ErrorCode   translationError
-	ErrorText   string
-	Timestamp   int64
+type writeRequest struct {
+	e    entry.Entry
+	done chan error
 }
 
-func NewCache(filePath string, cacheSize int, translators []string) (*Cache, error) {
-	db, err := sql.Open("sqlite3", filePath+"?cache=shared&mode=rwc&_synchronous=1&_auto_vacuum=2&_journal_mode=WAL&_busy_timeout=5000")
+// archiveQuery copies stored translation to history, arguments are reason, replaced time, key and the new translation
+const archiveQuery = `INSERT INTO History(service, fromLang, toLang, text, translation, errorCode, errorText, time, raw, postProcess, context, reason, replaced)
+	SELECT service, fromLang, toLang, text, translation, errorCode, errorText, time, raw, postProcess, context, ?, ? FROM Translations
+	WHERE service = ? AND fromLang = ? AND toLang = ? AND text = ? AND errorCode = 0 AND translation != ?`
+
+func NewCache(filePath string, opts Options) (*Cache, error) {
+	db, err := sql.Open("sqlite3", filePath+"?_synchronous=1&_auto_vacuum=2&_journal_mode=WAL&_busy_timeout=5000")
 	if err != nil {
-		log.Fatal(err)
-		return nil, err
+		return nil, errors.Wrap(err, "failed to open database")
 	}
 
 	// Avoids database is locked errors
 	db.SetMaxOpenConns(1)
 
-	if _, err := db.Exec(fmt.Sprintf("PRAGMA cache_size = -%d", cacheSize)); err != nil {
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA cache_size = -%d", opts.CacheSize)); err != nil {
 		log.Errorf("Failed to increase page cache size! %s", err)
 	} else {
-		log.Infof("Page cache set to %d kib", cacheSize)
+		log.Infof("Page cache set to %d kib", opts.CacheSize)
 	}
 
-	store := make(map[string]*lru.TwoQueueCache)
-	for _, t := range translators {
-		s, err := lru.New2Q(5000)
-		if err != nil {
-			log.Fatalf("Can't start memory cache for %s", t)
-		}
+	cache := &Cache{
+		path:        filePath,
+		db:          db,
+		loadAll:     make(map[int]*sql.Stmt),
+		loadAllLock: &sync.Mutex{},
+		closeLock:   &sync.RWMutex{},
+	}
+
+	cache.migrateDatabase()
 
-		store[t] = s
+	if err := cache.openReader(filePath, opts); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	cache := &Cache{db: db, lrustore: store}
+	if err := cache.prepare(); err != nil {
+		cache.reader.Close()
+		db.Close()
+		return nil, err
+	}
 
-	cache.migrateDatabase()
+	if opts.BatchWindow > 0 {
+		cache.writes = make(chan writeRequest)
+		cache.writerDone = make(chan struct{})
+
+		go cache.batchWriter(opts.BatchWindow)
+	}
 
 	return cache, nil
 }
 
+// openReader opens read only connection pool, database has to exist and be in WAL mode by now
+func (c *Cache) openReader(filePath string, opts Options) error {
+	readers := opts.Readers
+	if readers <= 0 {
+		readers = runtime.NumCPU()
+	}
+
+	cacheSize := opts.CacheSize / readers
+	if cacheSize < 2000 {
+		cacheSize = 2000
+	}
+
+	// Query parameters are only passed to sqlite when path is an URI
+	dsn := fmt.Sprintf("file:%s?mode=ro&_query_only=1&_busy_timeout=5000&_cache_size=-%d", filePath, cacheSize)
+
+	reader, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return errors.Wrap(err, "failed to open read only database")
+	}
+
+	reader.SetMaxOpenConns(readers)
+	reader.SetMaxIdleConns(readers)
+
+	if err := reader.Ping(); err != nil {
+		reader.Close()
+		return errors.Wrap(err, "failed to open read only database")
+	}
+
+	log.Infof("Using %d read only connections", readers)
+
+	c.reader = reader
+
+	return nil
+}
+
+// prepare creates statements used for every lookup and write so they don't have to be parsed each time
+func (c *Cache) prepare() error {
+	var err error
+
+	c.load, err = c.reader.Prepare("SELECT translation, errorCode, errorText, time, raw, postProcess, context, manual, pinned FROM Translations WHERE service = ? AND fromLang = ? AND toLang = ? AND text = ?")
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare select")
+	}
+
+	// Read statistics and pin survive translation being replaced
+	c.save, err = c.db.Prepare(`INSERT INTO Translations(text, service, fromLang, toLang, translation, errorCode, errorText, time, raw, postProcess, context, manual, lastAccess)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(service, fromLang, toLang, text) DO UPDATE SET
+		translation = excluded.translation, errorCode = excluded.errorCode, errorText = excluded.errorText, time = excluded.time,
+		raw = excluded.raw, postProcess = excluded.postProcess, context = excluded.context, manual = excluded.manual`)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare insert")
+	}
+
+	// Only successful translations are worth keeping, and only when they are actually replaced by something else
+	c.archive, err = c.db.Prepare(archiveQuery)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare archive")
+	}
+
+	c.delete, err = c.db.Prepare("DELETE FROM Translations WHERE service = ? AND fromLang = ? AND toLang = ? AND text = ?")
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare delete")
+	}
+
+	return nil
+}
+
 func (c *Cache) Close() error {
-	log.Println("VACUUM")
-	c.db.Exec("VACUUM")
+	c.closeLock.Lock()
+	c.closed = true
+	c.closeLock.Unlock()
+
+	if c.writes != nil {
+		close(c.writes)
+		<-c.writerDone
+	}
 
+	c.load.Close()
+	c.save.Close()
+	c.archive.Close()
+	c.delete.Close()
+
+	c.loadAllLock.Lock()
+	for _, stmt := range c.loadAll {
+		stmt.Close()
+	}
+	c.loadAllLock.Unlock()
+
+	c.reader.Close()
+
+	// Full VACUUM is way too slow for shutdown on large databases, maintenance takes care of reclaiming space
 	log.Println("Writing checkpoint")
 	c.db.Exec("PRAGMA wal_checkpoint")
 
 	return c.db.Close()
 }
 
-func (c *Cache) Put(bucketName, text, translation string, cerr error) error {
-	errorCode := errorNone
-	errorText := ""
+func (c *Cache) Save(e entry.Entry) error {
+	c.closeLock.RLock()
+	defer c.closeLock.RUnlock()
 
-	if cerr != nil {
-		errorText = cerr.Error()
+	if c.closed {
+		return errors.New("database is closed")
+	}
 
-		switch cerr.(type) {
-		case translator.BadTranslationError:
-			errorCode = errorBadTranslation
-		default:
-			errorCode = errorMinor
-		}
+	if c.writes == nil {
+		return c.Replace(e, entry.ReasonRefresh)
 	}
 
-	stmt, err := c.db.Prepare("INSERT OR REPLACE INTO Translations(text, service, translation, errorCode, errorText, time) VALUES (?, ?, ?, ?, ?, ?)")
-	if err != nil {
-		log.Fatal(err)
+	req := writeRequest{e: e, done: make(chan error, 1)}
+	c.writes <- req
+
+	return <-req.done
+}
+
+// SaveBatch stores all entries in a single transaction
+func (c *Cache) SaveBatch(entries []entry.Entry) error {
+	return c.replace(entries, entry.ReasonRefresh)
+}
+
+// Replace stores entry, translation it replaces is kept in history
+func (c *Cache) Replace(e entry.Entry, reason entry.Reason) error {
+	return c.replace([]entry.Entry{e}, reason)
+}
+
+func (c *Cache) replace(entries []entry.Entry, reason entry.Reason) error {
+	if len(entries) == 0 {
+		return nil
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(text, bucketName, translation, errorCode, errorText, time.Now().UTC().Unix())
+	tx, err := c.db.Begin()
 	if err != nil {
-		log.Fatal(err)
+		return errors.Wrap(err, "failed to start transaction")
 	}
 
-	// Add to memory cache
-	i := Item{
-		Translation: translation,
-		ErrorCode:   errorCode,
-		ErrorText:   errorText,
-		Timestamp:   time.Now().UTC().Unix(),
+	archive := tx.Stmt(c.archive)
+	defer archive.Close()
+
+	save := tx.Stmt(c.save)
+	defer save.Close()
+
+	now := time.Now().UTC().Unix()
+
+	for _, e := range entries {
+		if _, err := archive.Exec(reason, now, e.Service, e.From, e.To, e.Text, e.Translation); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "failed to archive %q", e.Text)
+		}
+
+		if _, err := save.Exec(e.Text, e.Service, e.From, e.To, e.Translation, e.ErrorCode, e.ErrorText, e.Timestamp.UTC().Unix(), e.Raw, e.PostProcess, e.Context, e.Manual, lastAccess(e)); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "failed to insert %q", e.Text)
+		}
 	}
 
-	c.lrustore[bucketName].Add(text, i)
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit insert")
+	}
 
-	return err
+	return nil
 }
 
-func (c *Cache) Get(bucketName, text string) (string, bool, error) {
-	var found bool
-	var id int64
-	var translation string
-	var errorCode translationError
-	var errorText string
-	var timestamp int64
-	var err error
+// batchWriter collects writes that arrive within window of the first one and commits them together,
+// every caller still waits until its write is committed
+func (c *Cache) batchWriter(window time.Duration) {
+	defer close(c.writerDone)
+
+	for first := range c.writes {
+		batch := []writeRequest{first}
+		timer := time.NewTimer(window)
+
+	collect:
+		for len(batch) < maxBatchSize {
+			select {
+			case req, ok := <-c.writes:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, req)
+			case <-timer.C:
+				break collect
+			}
+		}
 
-	item, ok := c.lrustore[bucketName].Get(text)
-	if ok {
-		i := item.(Item)
+		timer.Stop()
 
-		translation = i.Translation
-		errorCode = i.ErrorCode
-		errorText = i.ErrorText
-	} else {
-		stmt, err := c.db.Prepare("SELECT id, translation, errorCode, errorText, time FROM Translations WHERE service = ? AND text = ?")
-		if err != nil {
-			log.Fatal(err)
+		entries := make([]entry.Entry, len(batch))
+		for i, req := range batch {
+			entries[i] = req.e
 		}
-		defer stmt.Close()
 
-		err = stmt.QueryRow(bucketName, text).Scan(&id, &translation, &errorCode, &errorText, &timestamp)
-		if err != nil {
-			return translation, found, nil
+		err := c.SaveBatch(entries)
+		for _, req := range batch {
+			req.done <- err
 		}
 	}
+}
+
+func (c *Cache) Load(key entry.Key) (entry.Entry, bool, error) {
+	e := entry.Entry{Key: key}
+
+	var timestamp int64
 
-	if translation != "" {
-		found = true
+	err := c.load.QueryRow(key.Service, key.From, key.To, key.Text).Scan(&e.Translation, &e.ErrorCode, &e.ErrorText, &timestamp, &e.Raw, &e.PostProcess, &e.Context, &e.Manual, &e.Pinned)
+	if err == sql.ErrNoRows {
+		return e, false, nil
+	} else if err != nil {
+		return e, false, errors.Wrap(err, "failed to execute select")
 	}
 
-	if errorCode != errorNone {
-		errorTime := time.Unix(timestamp, 0)
+	e.Timestamp = time.Unix(timestamp, 0).UTC()
 
-		if time.Since(errorTime) > getCacheExpiration(errorCode) {
-			if id != 0 {
-				_, err = c.db.Exec(fmt.Sprintf("DELETE FROM Translations WHERE id = %d", id))
-				if err != nil {
-					log.Warn("unable to delete item: ", err)
-				}
-			}
+	return e, true, nil
+}
 
-			c.lrustore[bucketName].Remove(text)
+func (c *Cache) LoadAll(services []string, from, to, text string) ([]entry.Entry, error) {
+	if len(services) == 0 {
+		return nil, nil
+	}
+
+	stmt, err := c.loadAllStmt(len(services))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, 0, len(services)+3)
+	for _, service := range services {
+		args = append(args, service)
+	}
+	args = append(args, from, to, text)
 
-			// Act as if nothing was found
-			return "", false, nil
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute select")
+	}
+	defer rows.Close()
+
+	var entries []entry.Entry
+
+	for rows.Next() {
+		e := entry.Entry{Key: entry.Key{From: from, To: to, Text: text}}
+
+		var timestamp int64
+
+		if err := rows.Scan(&e.Service, &e.Translation, &e.ErrorCode, &e.ErrorText, &timestamp, &e.Raw, &e.PostProcess, &e.Context, &e.Manual, &e.Pinned); err != nil {
+			return nil, errors.Wrap(err, "failed to read row")
 		}
 
-		return "", found, fmt.Errorf("%s", errorText)
+		e.Timestamp = time.Unix(timestamp, 0).UTC()
+
+		entries = append(entries, e)
 	}
 
-	return translation, found, nil
+	return entries, rows.Err()
+}
+
+// loadAllStmt returns prepared LoadAll statement for given number of services, there are only ever a few of them
+func (c *Cache) loadAllStmt(n int) (*sql.Stmt, error) {
+	c.loadAllLock.Lock()
+	defer c.loadAllLock.Unlock()
+
+	if stmt, ok := c.loadAll[n]; ok {
+		return stmt, nil
+	}
+
+	query := fmt.Sprintf("SELECT service, translation, errorCode, errorText, time, raw, postProcess, context, manual, pinned FROM Translations WHERE service IN (?%s) AND fromLang = ? AND toLang = ? AND text = ?", strings.Repeat(", ?", n-1))
+
+	stmt, err := c.reader.Prepare(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare select")
+	}
+
+	c.loadAll[n] = stmt
+
+	return stmt, nil
+}
+
+func (c *Cache) Delete(key entry.Key) error {
+	_, err := c.delete.Exec(key.Service, key.From, key.To, key.Text)
+
+	return err
 }
@@ -0,0 +1,377 @@
+package sqlite
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+)
+
+func newTestCache(t testing.TB) *Cache {
+	return newTestCacheWith(t, Options{CacheSize: 2000})
+}
+
+func newTestCacheWith(t testing.TB, opts Options) *Cache {
+	c, err := NewCache(filepath.Join(t.TempDir(), "translation.db"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+func TestCache_Load(t *testing.T) {
+	c := newTestCache(t)
+
+	stored := entry.Entry{
+		Key:         entry.Key{Service: "Bing", From: "ja", To: "en", Text: "テスト"},
+		ErrorCode:   entry.ErrorMinor,
+		ErrorText:   "connection refused",
+		Timestamp:   time.Now().Add(-10 * time.Minute).Truncate(time.Second).UTC(),
+		Raw:         "test __",
+		PostProcess: 2,
+		Context:     `[{"text":"前","translation":"before"}]`,
+	}
+
+	if err := c.Save(stored); err != nil {
+		t.Fatal(err)
+	}
+
+	got, found, err := c.Load(stored.Key)
+	if err != nil || !found {
+		t.Fatalf("Load() found = %v, err = %v", found, err)
+	}
+
+	if got != stored {
+		t.Errorf("Load() = %+v, want %+v", got, stored)
+	}
+
+	if _, found, _ := c.Load(entry.Key{Service: "Bing", From: "ja", To: "en", Text: "missing"}); found {
+		t.Error("Load() found missing entry")
+	}
+}
+
+func TestCache_SaveBatched(t *testing.T) {
+	c := newTestCacheWith(t, Options{CacheSize: 2000, BatchWindow: 10 * time.Millisecond})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			e := entry.Entry{
+				Key:         entry.Key{Service: "Google", From: "ja", To: "en", Text: fmt.Sprintf("text %d", i)},
+				Translation: fmt.Sprintf("translation %d", i),
+				Timestamp:   time.Now(),
+			}
+
+			if err := c.Save(e); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if count, err := c.Count(); err != nil || count != 50 {
+		t.Errorf("Count() = %d, %v, want 50", count, err)
+	}
+}
+
+func fillBenchCache(b *testing.B, c *Cache, n int) []entry.Key {
+	entries := make([]entry.Entry, n)
+	keys := make([]entry.Key, n)
+
+	for i := range entries {
+		keys[i] = entry.Key{Service: "Google", From: "ja", To: "en", Text: fmt.Sprintf("テキスト %d", i)}
+		entries[i] = entry.Entry{Key: keys[i], Translation: fmt.Sprintf("text %d", i), Timestamp: time.Now()}
+	}
+
+	if err := c.SaveBatch(entries); err != nil {
+		b.Fatal(err)
+	}
+
+	return keys
+}
+
+func benchmarkLoadParallel(b *testing.B, readers int) {
+	c := newTestCacheWith(b, Options{CacheSize: 2000, Readers: readers})
+	keys := fillBenchCache(b, c, 10000)
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, _, err := c.Load(keys[i%len(keys)]); err != nil {
+				b.Error(err)
+			}
+			i += 7
+		}
+	})
+}
+
+func BenchmarkCache_LoadParallel_1Reader(b *testing.B)  { benchmarkLoadParallel(b, 1) }
+func BenchmarkCache_LoadParallel_4Readers(b *testing.B) { benchmarkLoadParallel(b, 4) }
+func BenchmarkCache_LoadParallel_8Readers(b *testing.B) { benchmarkLoadParallel(b, 8) }
+
+// Lookups while something keeps writing in the background
+func BenchmarkCache_LoadParallelWithWrites(b *testing.B) {
+	c := newTestCacheWith(b, Options{CacheSize: 2000, BatchWindow: time.Millisecond})
+	keys := fillBenchCache(b, c, 10000)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			c.Save(entry.Entry{Key: keys[i%len(keys)], Translation: "updated", Timestamp: time.Now()})
+		}
+	}()
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, _, err := c.Load(keys[i%len(keys)]); err != nil {
+				b.Error(err)
+			}
+			i += 7
+		}
+	})
+
+	b.StopTimer()
+
+	close(stop)
+	<-done
+}
+
+func TestCache_LoadAll(t *testing.T) {
+	c := newTestCache(t)
+
+	for _, service := range []string{"Google", "Bing", "Yandex"} {
+		c.Save(entry.Entry{Key: entry.Key{Service: service, From: "ja", To: "en", Text: "テスト"}, Translation: service, Timestamp: time.Now()})
+	}
+	c.Save(entry.Entry{Key: entry.Key{Service: "Google", From: "ja", To: "ru", Text: "テスト"}, Translation: "ru", Timestamp: time.Now()})
+
+	got, err := c.LoadAll([]string{"Google", "Bing", "Missing"}, "ja", "en", "テスト")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("LoadAll() returned %d entries, want 2: %+v", len(got), got)
+	}
+
+	for _, e := range got {
+		if e.Translation != e.Service || e.To != "en" {
+			t.Errorf("LoadAll() returned wrong entry %+v", e)
+		}
+	}
+}
+
+func TestCache_History(t *testing.T) {
+	c := newTestCache(t)
+
+	key := entry.Key{Service: "Google", From: "ja", To: "en", Text: "テスト"}
+
+	c.Save(entry.Entry{Key: key, Translation: "first", Timestamp: time.Now()})
+	c.Save(entry.Entry{Key: key, Translation: "first", Timestamp: time.Now()})
+	c.Save(entry.Entry{Key: key, Translation: "second", Timestamp: time.Now()})
+	c.Replace(entry.Entry{Key: key, Translation: "edited", Timestamp: time.Now()}, entry.ReasonManual)
+
+	versions, err := c.History(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving the same translation again doesn't add a version
+	if len(versions) != 2 {
+		t.Fatalf("History() returned %d versions, want 2: %+v", len(versions), versions)
+	}
+
+	if versions[0].Translation != "second" || versions[0].Reason != entry.ReasonManual {
+		t.Errorf("History()[0] = %+v, want second replaced by manual edit", versions[0])
+	}
+
+	if versions[1].Translation != "first" || versions[1].Reason != entry.ReasonRefresh {
+		t.Errorf("History()[1] = %+v, want first replaced by refresh", versions[1])
+	}
+
+	v, found, err := c.Version(key, versions[1].ID)
+	if err != nil || !found || v != versions[1] {
+		t.Errorf("Version() = %+v, %v, %v", v, found, err)
+	}
+
+	if _, found, _ := c.Version(entry.Key{Service: "Bing", From: "ja", To: "en", Text: "テスト"}, versions[1].ID); found {
+		t.Error("Version() found version of another key")
+	}
+}
+
+func TestCache_ImportOverwrite(t *testing.T) {
+	c := newTestCache(t)
+
+	key := entry.Key{Service: "Google", From: "ja", To: "en", Text: "テスト"}
+
+	c.Replace(entry.Entry{Key: key, Translation: "edited", Timestamp: time.Now(), Manual: true}, entry.ReasonManual)
+
+	if _, err := c.Import([]entry.Entry{{Key: key, Translation: "imported", Timestamp: time.Now()}}, entry.Overwrite); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := c.History(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(versions) != 1 || versions[0].Translation != "edited" || versions[0].Reason != entry.ReasonImport {
+		t.Errorf("History() = %+v, want edited version replaced by import", versions)
+	}
+}
+
+func TestCache_ImportNewestWins(t *testing.T) {
+	c := newTestCache(t)
+
+	older := entry.Key{Service: "Google", From: "ja", To: "en", Text: "古い"}
+	newer := entry.Key{Service: "Google", From: "ja", To: "en", Text: "新しい"}
+
+	c.Replace(entry.Entry{Key: older, Translation: "old", Timestamp: time.Now().Add(-time.Hour)}, entry.ReasonManual)
+	c.Replace(entry.Entry{Key: newer, Translation: "kept", Timestamp: time.Now()}, entry.ReasonManual)
+
+	imported := []entry.Entry{
+		{Key: older, Translation: "imported", Timestamp: time.Now().Add(-time.Minute)},
+		{Key: newer, Translation: "imported", Timestamp: time.Now().Add(-time.Minute)},
+	}
+
+	if n, err := c.Import(imported, entry.NewestWins); err != nil || n != 1 {
+		t.Fatalf("Import() = %d, %v, want 1 replaced", n, err)
+	}
+
+	versions, err := c.History(older)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(versions) != 1 || versions[0].Translation != "old" || versions[0].Reason != entry.ReasonImport {
+		t.Errorf("History() = %+v, want old version replaced by import", versions)
+	}
+
+	if versions, _ := c.History(newer); len(versions) != 0 {
+		t.Errorf("History() = %+v, want nothing archived for entry import didn't replace", versions)
+	}
+}
+
+func TestCache_Evict(t *testing.T) {
+	c := newTestCache(t)
+
+	keys := make([]entry.Key, 4)
+	for i := range keys {
+		keys[i] = entry.Key{Service: "Google", From: "ja", To: "en", Text: fmt.Sprintf("text %d", i)}
+		c.Save(entry.Entry{Key: keys[i], Translation: "translation", Timestamp: time.Now().Add(-time.Hour)})
+	}
+
+	// First one was stored first but read most recently, second is pinned and saving it again keeps the pin
+	if err := c.RecordHits(map[entry.Key]entry.Hits{keys[0]: {Count: 3, Last: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if found, err := c.SetPinned(keys[1], true); err != nil || !found {
+		t.Fatalf("SetPinned() = %v, %v", found, err)
+	}
+
+	c.Save(entry.Entry{Key: keys[1], Translation: "refreshed", Timestamp: time.Now()})
+
+	evicted, err := c.Evict(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if evicted != 2 {
+		t.Errorf("Evict() = %d, want 2", evicted)
+	}
+
+	for i, wantFound := range []bool{true, true, false, false} {
+		if _, found, _ := c.Load(keys[i]); found != wantFound {
+			t.Errorf("Load(%q) found = %v, want %v", keys[i].Text, found, wantFound)
+		}
+	}
+
+	e, found, err := c.Load(keys[1])
+	if err != nil || !found || !e.Pinned || e.Translation != "refreshed" {
+		t.Errorf("Load() pinned entry = %+v, %v, %v", e, found, err)
+	}
+
+	if size, err := c.Size(); err != nil || size <= 0 {
+		t.Errorf("Size() = %d, %v", size, err)
+	}
+}
+
+func TestCache_Hot(t *testing.T) {
+	c := newTestCache(t)
+
+	for i, text := range []string{"a", "b", "c"} {
+		key := entry.Key{Service: "Google", From: "ja", To: "en", Text: text}
+		c.Save(entry.Entry{Key: key, Translation: text, Timestamp: time.Now()})
+		c.RecordHits(map[entry.Key]entry.Hits{key: {Count: int64(i), Last: time.Now()}})
+	}
+	c.Save(entry.Entry{Key: entry.Key{Service: "Google", From: "ja", To: "en", Text: "d"}, ErrorCode: entry.ErrorMinor, Timestamp: time.Now()})
+
+	hot, err := c.Hot("Google", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hot) != 2 || hot[0].Text != "c" || hot[1].Text != "b" {
+		t.Errorf("Hot() = %+v, want c and b", hot)
+	}
+}
+
+func TestCache_Backup(t *testing.T) {
+	c := newTestCache(t)
+
+	key := entry.Key{Service: "Google", From: "ja", To: "en", Text: "テスト"}
+	if err := c.Save(entry.Entry{Key: key, Translation: "test", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "backup.db")
+	if err := c.Backup(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Existing file is never overwritten
+	if err := c.Backup(path); err == nil {
+		t.Error("expected error when backup file already exists")
+	}
+
+	backup, err := NewCache(path, Options{CacheSize: 2000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	got, found, err := backup.Load(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !found || got.Translation != "test" {
+		t.Errorf("Load() from backup = %+v, %v", got, found)
+	}
+}
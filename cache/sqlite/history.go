@@ -0,0 +1,69 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+)
+
+const historyColumns = "id, translation, errorCode, errorText, time, raw, postProcess, context, reason, replaced"
+
+// History returns translations that were stored for key before, newest first
+func (c *Cache) History(key entry.Key) ([]entry.Version, error) {
+	rows, err := c.reader.Query("SELECT "+historyColumns+" FROM History WHERE service = ? AND fromLang = ? AND toLang = ? AND text = ? ORDER BY id DESC",
+		key.Service, key.From, key.To, key.Text)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select history")
+	}
+	defer rows.Close()
+
+	var versions []entry.Version
+
+	for rows.Next() {
+		v, err := scanVersion(rows, key)
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, v)
+	}
+
+	return versions, rows.Err()
+}
+
+// Version returns a single version from history
+func (c *Cache) Version(key entry.Key, id int64) (entry.Version, bool, error) {
+	row := c.reader.QueryRow("SELECT "+historyColumns+" FROM History WHERE id = ? AND service = ? AND fromLang = ? AND toLang = ? AND text = ?",
+		id, key.Service, key.From, key.To, key.Text)
+
+	v, err := scanVersion(row, key)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return v, false, nil
+	} else if err != nil {
+		return v, false, err
+	}
+
+	return v, true, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanVersion(row scanner, key entry.Key) (entry.Version, error) {
+	v := entry.Version{Entry: entry.Entry{Key: key}}
+
+	var timestamp, replaced int64
+
+	if err := row.Scan(&v.ID, &v.Translation, &v.ErrorCode, &v.ErrorText, &timestamp, &v.Raw, &v.PostProcess, &v.Context, &v.Reason, &replaced); err != nil {
+		return v, errors.Wrap(err, "failed to read history")
+	}
+
+	v.Timestamp = time.Unix(timestamp, 0).UTC()
+	v.Replaced = time.Unix(replaced, 0).UTC()
+
+	return v, nil
+}
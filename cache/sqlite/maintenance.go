@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+	"github.com/pkg/errors"
+)
+
+// DeleteOlder deletes entries from service with given error code stored before given time
+func (c *Cache) DeleteOlder(service string, code entry.ErrorCode, before time.Time) (int64, error) {
+	res, err := c.db.Exec("DELETE FROM Translations WHERE service = ? AND errorCode = ? AND time < ?", service, code, before.UTC().Unix())
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to delete expired entries")
+	}
+
+	return res.RowsAffected()
+}
+
+// Checkpoint moves everything from write-ahead log into the database and truncates it
+func (c *Cache) Checkpoint() error {
+	_, err := c.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+
+	return err
+}
+
+// IncrementalVacuum frees up to given number of unused pages, 0 frees all of them
+func (c *Cache) IncrementalVacuum(pages int) (int64, error) {
+	var before, after int64
+
+	if err := c.db.QueryRow("PRAGMA freelist_count").Scan(&before); err != nil {
+		return 0, err
+	}
+
+	rows, err := c.db.Query(fmt.Sprintf("PRAGMA incremental_vacuum(%d)", pages))
+	if err != nil {
+		return 0, err
+	}
+
+	// Pages are freed while stepping through results
+	for rows.Next() {
+	}
+	rows.Close()
+
+	if err := c.db.QueryRow("PRAGMA freelist_count").Scan(&after); err != nil {
+		return 0, err
+	}
+
+	return before - after, nil
+}
+
+// Services lists every service that has something stored
+func (c *Cache) Services() ([]string, error) {
+	rows, err := c.reader.Query("SELECT DISTINCT service FROM Translations")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list services")
+	}
+	defer rows.Close()
+
+	var out []string
+
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+
+		out = append(out, s)
+	}
+
+	return out, rows.Err()
+}
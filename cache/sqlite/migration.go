@@ -1,8 +1,10 @@
 package sqlite
 
 import (
+	"os"
 	"time"
 
+	"gitgud.io/softashell/comfy-translator/cache/entry"
 	"github.com/asdine/storm"
 	log "github.com/sirupsen/logrus"
 )
@@ -10,12 +12,12 @@ import (
 type StormItem struct {
 	Text        string `storm:"id"` // primary key
 	Translation string
-	ErrorCode   translationError `storm:"index"`
+	ErrorCode   entry.ErrorCode `storm:"index"`
 	ErrorText   string
 	Timestamp   int64
 }
 
-const latestVersion = 1
+const latestVersion = 6
 
 func (c *Cache) migrateDatabase() error {
 	latestMigration := 0
@@ -57,6 +59,16 @@ func (c *Cache) migrate(ver int) int {
 	switch tgt {
 	case 1:
 		err = c.migration1()
+	case 2:
+		err = c.migration2()
+	case 3:
+		err = c.migration3()
+	case 4:
+		err = c.migration4()
+	case 5:
+		err = c.migration5()
+	case 6:
+		err = c.migration6()
 	}
 
 	log := log.WithFields(log.Fields{
@@ -121,7 +133,174 @@ func (c *Cache) migration1() error {
 	return err
 }
 
+// Adds language pair to translations, everything cached before was japanese to english
+func (c *Cache) migration2() error {
+	log.Print("Migration #2")
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = execTxAndPrint(tx, `ALTER TABLE Translations ADD COLUMN fromLang TEXT NOT NULL DEFAULT 'ja';`); err != nil {
+		return err
+	}
+
+	if err = execTxAndPrint(tx, `ALTER TABLE Translations ADD COLUMN toLang TEXT NOT NULL DEFAULT 'en';`); err != nil {
+		return err
+	}
+
+	if err = execTxAndPrint(tx, `DROP INDEX "translation_idx";`); err != nil {
+		return err
+	}
+
+	// Column order matches the order used by Scan
+	if err = execTxAndPrint(tx,
+		`CREATE UNIQUE INDEX "translation_idx" ON "Translations" (
+			"service",
+			"fromLang",
+			"toLang",
+			"text"
+			);`); err != nil {
+		return err
+	}
+
+	if err = execTxAndPrint(tx, `INSERT INTO migrations VALUES (2)`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Adds table keeping translations that got replaced
+func (c *Cache) migration3() error {
+	log.Print("Migration #3")
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = execTxAndPrint(tx,
+		`CREATE TABLE IF NOT EXISTS History (
+			id INTEGER PRIMARY KEY,
+			service TEXT NOT NULL,
+			fromLang TEXT NOT NULL,
+			toLang TEXT NOT NULL,
+			text TEXT NOT NULL,
+			translation TEXT NOT NULL,
+			errorCode INT,
+			errorText TEXT,
+			time INT,
+			reason TEXT NOT NULL,
+			replaced INT NOT NULL
+			);`); err != nil {
+		return err
+	}
+
+	if err = execTxAndPrint(tx,
+		`CREATE INDEX "history_idx" ON "History" (
+			"service",
+			"fromLang",
+			"toLang",
+			"text"
+			);`); err != nil {
+		return err
+	}
+
+	if err = execTxAndPrint(tx, `INSERT INTO migrations VALUES (3)`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Keeps raw engine output so cleanup can be applied again when it changes
+func (c *Cache) migration4() error {
+	log.Print("Migration #4")
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{"Translations", "History"} {
+		if err = execTxAndPrint(tx, `ALTER TABLE `+table+` ADD COLUMN raw TEXT NOT NULL DEFAULT '';`); err != nil {
+			return err
+		}
+
+		if err = execTxAndPrint(tx, `ALTER TABLE `+table+` ADD COLUMN postProcess INT NOT NULL DEFAULT 0;`); err != nil {
+			return err
+		}
+	}
+
+	if err = execTxAndPrint(tx, `INSERT INTO migrations VALUES (4)`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Tracks reads so least recently used translations can be evicted, manual and pinned ones are kept
+func (c *Cache) migration5() error {
+	log.Print("Migration #5")
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, column := range []string{"hits", "lastAccess", "manual", "pinned"} {
+		if err = execTxAndPrint(tx, `ALTER TABLE Translations ADD COLUMN `+column+` INT NOT NULL DEFAULT 0;`); err != nil {
+			return err
+		}
+	}
+
+	if err = execTxAndPrint(tx, `UPDATE Translations SET lastAccess = time;`); err != nil {
+		return err
+	}
+
+	if err = execTxAndPrint(tx,
+		`CREATE INDEX "eviction_idx" ON "Translations" ("lastAccess")
+			WHERE errorCode = 0 AND manual = 0 AND pinned = 0;`); err != nil {
+		return err
+	}
+
+	if err = execTxAndPrint(tx, `INSERT INTO migrations VALUES (5)`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Records previous lines of conversation that context aware engines translated with
+func (c *Cache) migration6() error {
+	log.Print("Migration #6")
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{"Translations", "History"} {
+		if err = execTxAndPrint(tx, `ALTER TABLE `+table+` ADD COLUMN context TEXT NOT NULL DEFAULT '';`); err != nil {
+			return err
+		}
+	}
+
+	if err = execTxAndPrint(tx, `INSERT INTO migrations VALUES (6)`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (c *Cache) migrateFromStorm() {
+	// Opening would create an empty database, nothing to import then
+	if _, err := os.Stat("_translation.db"); os.IsNotExist(err) {
+		return
+	}
+
 	storm, err := storm.Open("_translation.db", storm.Batch())
 	if err != nil {
 		log.Error("can't open legacy storm db (_translation.db) for import")
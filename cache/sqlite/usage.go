@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+)
+
+// RecordHits adds read counts and moves last access time forward
+func (c *Cache) RecordHits(hits map[entry.Key]entry.Hits) error {
+	if len(hits) == 0 {
+		return nil
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "failed to start transaction")
+	}
+
+	stmt, err := tx.Prepare("UPDATE Translations SET hits = hits + ?, lastAccess = max(lastAccess, ?) WHERE service = ? AND fromLang = ? AND toLang = ? AND text = ?")
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to prepare update")
+	}
+	defer stmt.Close()
+
+	for key, h := range hits {
+		if _, err := stmt.Exec(h.Count, h.Last.UTC().Unix(), key.Service, key.From, key.To, key.Text); err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "failed to record hits")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit hits")
+	}
+
+	return nil
+}
+
+// SetPinned changes whether entry is exempt from eviction, found is false when there's no such entry
+func (c *Cache) SetPinned(key entry.Key, pinned bool) (bool, error) {
+	res, err := c.db.Exec("UPDATE Translations SET pinned = ? WHERE service = ? AND fromLang = ? AND toLang = ? AND text = ?",
+		pinned, key.Service, key.From, key.To, key.Text)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update pin")
+	}
+
+	n, err := res.RowsAffected()
+
+	return n > 0, err
+}
+
+// Evict deletes up to n successful translations that weren't read for longest, manual and pinned ones are kept
+func (c *Cache) Evict(n int64) (int64, error) {
+	res, err := c.db.Exec(`DELETE FROM Translations WHERE id IN (
+		SELECT id FROM Translations WHERE errorCode = 0 AND manual = 0 AND pinned = 0 ORDER BY lastAccess LIMIT ?
+		)`, n)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to evict translations")
+	}
+
+	return res.RowsAffected()
+}
+
+// Size returns bytes used by database pages that hold data, free pages aren't counted
+func (c *Cache) Size() (int64, error) {
+	var size int64
+
+	err := c.reader.QueryRow("SELECT (page_count - freelist_count) * page_size FROM pragma_page_count(), pragma_freelist_count(), pragma_page_size()").Scan(&size)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get database size")
+	}
+
+	return size, nil
+}
+
+// Hot returns up to limit successful translations of service that were read most often
+func (c *Cache) Hot(service string, limit int) ([]entry.Entry, error) {
+	rows, err := c.reader.Query(`SELECT fromLang, toLang, text, translation, time, raw, postProcess, context, manual, pinned FROM Translations
+		WHERE service = ? AND errorCode = 0
+		ORDER BY hits DESC, lastAccess DESC
+		LIMIT ?`, service, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select hot translations")
+	}
+	defer rows.Close()
+
+	var out []entry.Entry
+
+	for rows.Next() {
+		e := entry.Entry{Key: entry.Key{Service: service}}
+
+		var timestamp int64
+
+		if err := rows.Scan(&e.From, &e.To, &e.Text, &e.Translation, &timestamp, &e.Raw, &e.PostProcess, &e.Context, &e.Manual, &e.Pinned); err != nil {
+			return nil, errors.Wrap(err, "failed to read translation")
+		}
+
+		e.Timestamp = time.Unix(timestamp, 0).UTC()
+
+		out = append(out, e)
+	}
+
+	return out, rows.Err()
+}
+
+// lastAccess falls back to stored time for entries that were never read
+func lastAccess(e entry.Entry) int64 {
+	if e.LastAccess.IsZero() {
+		return e.Timestamp.UTC().Unix()
+	}
+
+	return e.LastAccess.UTC().Unix()
+}
@@ -0,0 +1,470 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/pkg/errors"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+	"gitgud.io/softashell/comfy-translator/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+const memoryCacheSize = 5000
+
+// tieredCache keeps recently used entries from each bucket in memory and decides
+// when entries expire so every backend behaves the same way
+type tieredCache struct {
+	store       Store
+	expiry      entry.ExpiryPolicy
+	translators []string
+	lrustore    map[string]*lru.TwoQueueCache
+
+	// Set when writes are buffered and stored in batches
+	buffer *writeBehind
+	// Set when reads are counted
+	hits *hitRecorder
+
+	// Held for writing while entry is replaced, so results put at the same time land either before or after it
+	replacing *sync.RWMutex
+}
+
+func newTieredCache(store Store, expiry entry.ExpiryPolicy, translators []string) (*tieredCache, error) {
+	lrustore := make(map[string]*lru.TwoQueueCache)
+	for _, t := range translators {
+		s, err := lru.New2Q(memoryCacheSize)
+		if err != nil {
+			return nil, fmt.Errorf("can't start memory cache for %s: %v", t, err)
+		}
+
+		lrustore[t] = s
+	}
+
+	c := &tieredCache{
+		store:       store,
+		expiry:      expiry,
+		translators: translators,
+		lrustore:    lrustore,
+		replacing:   &sync.RWMutex{},
+	}
+
+	return c, nil
+}
+
+func (c *tieredCache) Put(bucketName string, req translator.Request, translation string, cerr error) error {
+	return c.PutResult(bucketName, req, Cached{Translation: translation, Err: cerr})
+}
+
+func (c *tieredCache) PutResult(bucketName string, req translator.Request, result Cached) error {
+	e := entry.Entry{
+		Key:         memoryKey(bucketName, req),
+		Translation: result.Translation,
+		Timestamp:   time.Now().UTC(),
+		Raw:         result.Raw,
+		PostProcess: result.PostProcess,
+		Context:     encodeContext(result.Context),
+	}
+
+	if result.Err != nil {
+		e.ErrorText = result.Err.Error()
+		e.ErrorCode = ErrorCode(result.Err)
+	}
+
+	c.replacing.RLock()
+	defer c.replacing.RUnlock()
+
+	// Add to memory cache first so it keeps serving even if backend is down
+	c.memory(bucketName).Add(e.Key, e)
+
+	if c.buffer != nil {
+		if err := c.buffer.add(e); err != nil {
+			return &BackendError{Op: "save", Err: err}
+		}
+
+		return nil
+	}
+
+	if err := c.store.Save(e); err != nil {
+		return &BackendError{Op: "save", Err: err}
+	}
+
+	return nil
+}
+
+// Get returns cached translation, found is true when anything was cached including errors.
+// Failing backend is reported as *BackendError with found set to false
+func (c *tieredCache) Get(bucketName string, req translator.Request) (string, bool, error) {
+	key := memoryKey(bucketName, req)
+
+	var e entry.Entry
+	var found bool
+
+	if item, ok := c.memory(bucketName).Get(key); ok {
+		e = item.(entry.Entry)
+		found = true
+	} else if pending, ok := c.pending(key); ok {
+		e = pending
+		found = true
+	} else {
+		var err error
+
+		e, found, err = c.store.Load(key)
+		if err != nil {
+			return "", false, &BackendError{Op: "load", Err: err}
+		}
+
+		if !found {
+			return "", false, nil
+		}
+
+		c.memory(bucketName).Add(key, e)
+	}
+
+	result, found := c.check(e)
+	if !found {
+		return "", false, nil
+	}
+
+	if result.Err == nil {
+		c.Served(bucketName, req)
+	}
+
+	return result.Translation, true, result.Err
+}
+
+// GetAll returns whatever every translator has cached for request, keyed by bucket name.
+// Entries missing from memory are loaded with a single backend query
+func (c *tieredCache) GetAll(req translator.Request) (map[string]Cached, error) {
+	results := make(map[string]Cached, len(c.translators))
+
+	var missing []string
+
+	for _, name := range c.translators {
+		key := memoryKey(name, req)
+
+		var e entry.Entry
+
+		if item, ok := c.memory(name).Get(key); ok {
+			e = item.(entry.Entry)
+		} else if pending, ok := c.pending(key); ok {
+			e = pending
+		} else {
+			missing = append(missing, name)
+			continue
+		}
+
+		if result, found := c.check(e); found {
+			results[name] = result
+		}
+	}
+
+	if len(missing) == 0 {
+		return results, nil
+	}
+
+	entries, err := c.store.LoadAll(missing, req.From, req.To, req.Text)
+	if err != nil {
+		return results, &BackendError{Op: "load", Err: err}
+	}
+
+	for _, e := range entries {
+		c.memory(e.Service).Add(e.Key, e)
+
+		if result, found := c.check(e); found {
+			results[e.Service] = result
+		}
+	}
+
+	return results, nil
+}
+
+func (c *tieredCache) Served(bucketName string, req translator.Request) {
+	if c.hits != nil {
+		c.hits.add(memoryKey(bucketName, req))
+	}
+}
+
+// check turns stored entry into result, expired entries are reported as not found.
+// Expired errors are deleted, expired translations are left to be replaced so they end up in history
+func (c *tieredCache) check(e entry.Entry) (Cached, bool) {
+	kept := e.ErrorCode == entry.ErrorNone && e.Kept()
+
+	if !kept && c.expiry.Expired(e.Service, e.ErrorCode, e.Timestamp) {
+		if e.ErrorCode != entry.ErrorNone {
+			if err := c.store.Delete(e.Key); err != nil {
+				log.Warn("unable to delete item: ", err)
+			}
+		}
+
+		c.memory(e.Service).Remove(e.Key)
+
+		// Act as if nothing was found
+		return Cached{}, false
+	}
+
+	result := Cached{
+		Translation: e.Translation,
+		Raw:         e.Raw,
+		PostProcess: e.PostProcess,
+		Context:     decodeContext(e.Context),
+	}
+
+	if e.ErrorCode != entry.ErrorNone {
+		result.Err = fmt.Errorf("%s", e.ErrorText)
+	}
+
+	return result, true
+}
+
+func (c *tieredCache) Edit(bucketName string, req translator.Request, translation string) error {
+	return c.replace(entry.Entry{
+		Key:         memoryKey(bucketName, req),
+		Translation: translation,
+		Timestamp:   time.Now().UTC(),
+		Manual:      true,
+	}, entry.ReasonManual)
+}
+
+func (c *tieredCache) Report(bucketName string, req translator.Request) error {
+	return c.replace(entry.Entry{
+		Key:       memoryKey(bucketName, req),
+		ErrorCode: entry.ErrorBadTranslation,
+		ErrorText: "reported as bad translation",
+		Timestamp: time.Now().UTC(),
+	}, entry.ReasonReport)
+}
+
+func (c *tieredCache) History(bucketName string, req translator.Request) ([]entry.Version, error) {
+	versions, err := c.store.History(memoryKey(bucketName, req))
+	if err != nil {
+		return nil, &BackendError{Op: "history", Err: err}
+	}
+
+	return versions, nil
+}
+
+func (c *tieredCache) Rollback(bucketName string, req translator.Request, id int64) (entry.Entry, error) {
+	key := memoryKey(bucketName, req)
+
+	v, found, err := c.store.Version(key, id)
+	if err != nil {
+		return entry.Entry{}, &BackendError{Op: "history", Err: err}
+	}
+
+	if !found {
+		return entry.Entry{}, fmt.Errorf("version %d of %s %q not found", id, bucketName, req.Text)
+	}
+
+	// Restored translation starts its expiry over
+	e := v.Entry
+	e.Timestamp = time.Now().UTC()
+
+	return e, c.replace(e, entry.ReasonRollback)
+}
+
+// replace stores entry right away, bypassing write buffer so buffered entry can't overwrite it later
+func (c *tieredCache) replace(e entry.Entry, reason entry.Reason) error {
+	c.replacing.Lock()
+	defer c.replacing.Unlock()
+
+	c.memory(e.Service).Remove(e.Key)
+
+	err := c.afterFlush(func() error {
+		if err := c.store.Replace(e, reason); err != nil {
+			return &BackendError{Op: "save", Err: err}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.memory(e.Service).Add(e.Key, e)
+
+	return nil
+}
+
+// afterFlush runs fn once buffered writes are stored and keeps them from being flushed while it runs
+func (c *tieredCache) afterFlush(fn func() error) error {
+	if c.buffer == nil {
+		return fn()
+	}
+
+	return c.buffer.flushed(fn)
+}
+
+func (c *tieredCache) Scan(after entry.Key, limit int) ([]entry.Entry, error) {
+	return c.store.Scan(after, limit)
+}
+
+func (c *tieredCache) Count() (int64, error) {
+	return c.store.Count()
+}
+
+func (c *tieredCache) Import(entries []entry.Entry, strategy entry.MergeStrategy) (int, error) {
+	var n int
+
+	// Buffered writes would otherwise land on top of imported entries later
+	err := c.afterFlush(func() error {
+		var err error
+		n, err = c.store.Import(entries, strategy)
+
+		return err
+	})
+
+	// Memory cache might hold stale copies of replaced entries
+	for _, e := range entries {
+		c.memory(e.Service).Remove(e.Key)
+	}
+
+	return n, err
+}
+
+// PurgeExpired deletes every error entry that outlived its expiration time
+func (c *tieredCache) PurgeExpired() (int64, error) {
+	services, err := c.store.Services()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+
+	var purged int64
+
+	for _, service := range services {
+		for _, code := range entry.ErrorCodes() {
+			cutoff, ok := c.expiry.Cutoff(service, code, now)
+			if !ok {
+				continue
+			}
+
+			n, err := c.store.DeleteOlder(service, code, cutoff)
+			if err != nil {
+				return purged, err
+			}
+
+			purged += n
+		}
+	}
+
+	return purged, nil
+}
+
+// backend returns storage behind memory cache so optional backend features can be reached
+func backend(c Cache) interface{} {
+	if t, ok := c.(*tieredCache); ok {
+		return t.store
+	}
+
+	return c
+}
+
+func (c *tieredCache) Close() error {
+	if c.buffer != nil {
+		if err := c.buffer.close(); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if c.hits != nil {
+		if err := c.hits.close(); err != nil {
+			log.Warn(err)
+		}
+	}
+
+	return c.store.Close()
+}
+
+// pending returns entry that is buffered but not written to backend yet
+func (c *tieredCache) pending(key entry.Key) (entry.Entry, bool) {
+	if c.buffer == nil {
+		return entry.Entry{}, false
+	}
+
+	return c.buffer.get(key)
+}
+
+// memory returns memory cache for bucket, unknown buckets get a throwaway one so callers don't have to check
+func (c *tieredCache) memory(bucketName string) memoryCache {
+	if s, ok := c.lrustore[bucketName]; ok {
+		return s
+	}
+
+	return noMemory{}
+}
+
+type memoryCache interface {
+	Add(key, value interface{})
+	Get(key interface{}) (interface{}, bool)
+	Contains(key interface{}) bool
+	Remove(key interface{})
+}
+
+type noMemory struct{}
+
+func (noMemory) Add(key, value interface{})              {}
+func (noMemory) Get(key interface{}) (interface{}, bool) { return nil, false }
+func (noMemory) Contains(key interface{}) bool           { return false }
+func (noMemory) Remove(key interface{})                  {}
+
+// ErrorCode maps translator errors to codes stored in cache
+func ErrorCode(err error) entry.ErrorCode {
+	switch {
+	case errors.As(err, &translator.BadTranslationError{}):
+		return entry.ErrorBadTranslation
+	case errors.As(err, &translator.RateLimitedError{}):
+		return entry.ErrorRateLimited
+	case errors.As(err, &translator.AuthFailedError{}):
+		return entry.ErrorAuthFailed
+	case errors.As(err, &translator.UnsupportedError{}):
+		return entry.ErrorUnsupported
+	case errors.As(err, &translator.TransientError{}):
+		return entry.ErrorTransient
+	case errors.As(err, &translator.BlockedError{}):
+		return entry.ErrorBlocked
+	}
+
+	return entry.ErrorMinor
+}
+
+func memoryKey(bucketName string, req translator.Request) entry.Key {
+	return entry.Key{
+		Service: bucketName,
+		From:    req.From,
+		To:      req.To,
+		Text:    req.Text,
+	}
+}
+
+func encodeContext(lines []translator.Line) string {
+	if len(lines) == 0 {
+		return ""
+	}
+
+	b, err := json.Marshal(lines)
+	if err != nil {
+		return ""
+	}
+
+	return string(b)
+}
+
+// decodeContext ignores broken context, it's only there for reference
+func decodeContext(s string) []translator.Line {
+	if s == "" {
+		return nil
+	}
+
+	var lines []translator.Line
+	if err := json.Unmarshal([]byte(s), &lines); err != nil {
+		log.Debugf("Ignoring invalid context %q: %v", s, err)
+		return nil
+	}
+
+	return lines
+}
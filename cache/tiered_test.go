@@ -0,0 +1,791 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+// memoryStore is a stand-in for a database backend
+type memoryStore struct {
+	entries map[entry.Key]entry.Entry
+	history []entry.Version
+	queries int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: make(map[entry.Key]entry.Entry)}
+}
+
+func (m *memoryStore) Load(key entry.Key) (entry.Entry, bool, error) {
+	e, ok := m.entries[key]
+	return e, ok, nil
+}
+
+func (m *memoryStore) LoadAll(services []string, from, to, text string) ([]entry.Entry, error) {
+	m.queries++
+
+	var out []entry.Entry
+	for _, service := range services {
+		if e, ok := m.entries[entry.Key{Service: service, From: from, To: to, Text: text}]; ok {
+			out = append(out, e)
+		}
+	}
+
+	return out, nil
+}
+
+func (m *memoryStore) Save(e entry.Entry) error {
+	m.entries[e.Key] = e
+	return nil
+}
+
+func (m *memoryStore) SaveBatch(entries []entry.Entry) error {
+	for _, e := range entries {
+		m.entries[e.Key] = e
+	}
+
+	return nil
+}
+
+func (m *memoryStore) Replace(e entry.Entry, reason entry.Reason) error {
+	if old, ok := m.entries[e.Key]; ok && old.ErrorCode == entry.ErrorNone && old.Translation != e.Translation {
+		m.history = append(m.history, entry.Version{Entry: old, ID: int64(len(m.history) + 1), Reason: reason, Replaced: time.Now()})
+	}
+
+	m.entries[e.Key] = e
+
+	return nil
+}
+
+func (m *memoryStore) History(key entry.Key) ([]entry.Version, error) {
+	var out []entry.Version
+	for i := len(m.history) - 1; i >= 0; i-- {
+		if m.history[i].Key == key {
+			out = append(out, m.history[i])
+		}
+	}
+
+	return out, nil
+}
+
+func (m *memoryStore) Version(key entry.Key, id int64) (entry.Version, bool, error) {
+	for _, v := range m.history {
+		if v.ID == id && v.Key == key {
+			return v, true, nil
+		}
+	}
+
+	return entry.Version{}, false, nil
+}
+
+func (m *memoryStore) RecordHits(hits map[entry.Key]entry.Hits) error {
+	for key, h := range hits {
+		if e, ok := m.entries[key]; ok {
+			e.Hits += h.Count
+			e.LastAccess = h.Last
+			m.entries[key] = e
+		}
+	}
+
+	return nil
+}
+
+func (m *memoryStore) SetPinned(key entry.Key, pinned bool) (bool, error) {
+	e, ok := m.entries[key]
+	if ok {
+		e.Pinned = pinned
+		m.entries[key] = e
+	}
+
+	return ok, nil
+}
+
+func (m *memoryStore) Evict(n int64) (int64, error) {
+	var candidates []entry.Entry
+	for _, e := range m.entries {
+		if e.ErrorCode == entry.ErrorNone && !e.Kept() {
+			candidates = append(candidates, e)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].LastAccess.Before(candidates[j].LastAccess) })
+
+	var evicted int64
+	for _, e := range candidates {
+		if evicted == n {
+			break
+		}
+
+		delete(m.entries, e.Key)
+		evicted++
+	}
+
+	return evicted, nil
+}
+
+func (m *memoryStore) Hot(service string, limit int) ([]entry.Entry, error) {
+	var out []entry.Entry
+	for _, e := range m.entries {
+		if e.Service == service && e.ErrorCode == entry.ErrorNone {
+			out = append(out, e)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Hits > out[j].Hits })
+
+	if len(out) > limit {
+		out = out[:limit]
+	}
+
+	return out, nil
+}
+
+// Size pretends every entry takes 100 bytes
+func (m *memoryStore) Size() (int64, error) {
+	return int64(len(m.entries)) * 100, nil
+}
+
+func (m *memoryStore) Delete(key entry.Key) error {
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *memoryStore) Scan(after entry.Key, limit int) ([]entry.Entry, error) {
+	var out []entry.Entry
+	for _, e := range m.entries {
+		if after.Less(e.Key) {
+			out = append(out, e)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Key.Less(out[j].Key) })
+
+	if len(out) > limit {
+		out = out[:limit]
+	}
+
+	return out, nil
+}
+
+func (m *memoryStore) Count() (int64, error) {
+	return int64(len(m.entries)), nil
+}
+
+func (m *memoryStore) Import(entries []entry.Entry, strategy entry.MergeStrategy) (int, error) {
+	for _, e := range entries {
+		m.entries[e.Key] = e
+	}
+
+	return len(entries), nil
+}
+
+func (m *memoryStore) Services() ([]string, error) {
+	seen := make(map[string]bool)
+	var out []string
+
+	for k := range m.entries {
+		if !seen[k.Service] {
+			seen[k.Service] = true
+			out = append(out, k.Service)
+		}
+	}
+
+	return out, nil
+}
+
+func (m *memoryStore) DeleteOlder(service string, code entry.ErrorCode, before time.Time) (int64, error) {
+	var n int64
+	for k, e := range m.entries {
+		if k.Service == service && e.ErrorCode == code && e.Timestamp.Before(before) {
+			delete(m.entries, k)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (m *memoryStore) Close() error {
+	return nil
+}
+
+var testPolicy = entry.ExpiryPolicy{
+	Default: map[entry.ErrorCode]time.Duration{
+		entry.ErrorNone:           365 * 24 * time.Hour,
+		entry.ErrorMinor:          30 * time.Minute,
+		entry.ErrorBadTranslation: 24 * time.Hour,
+	},
+	Services: map[string]map[entry.ErrorCode]time.Duration{
+		"Google": {entry.ErrorNone: entry.Never},
+	},
+}
+
+func storedEntry(service, text string, code entry.ErrorCode, age time.Duration) entry.Entry {
+	e := entry.Entry{
+		Key:       entry.Key{Service: service, From: "ja", To: "en", Text: text},
+		ErrorCode: code,
+		Timestamp: time.Now().Add(-age).UTC(),
+	}
+
+	if code == entry.ErrorNone {
+		e.Translation = "translated " + text
+	} else {
+		e.ErrorText = "failed " + text
+	}
+
+	return e
+}
+
+func Test_tieredCache_Get(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    entry.Entry
+		wantFound bool
+		wantErr   bool
+		wantKept  bool
+	}{
+		{"fresh error", storedEntry("Bing", "a", entry.ErrorMinor, 29*time.Minute), true, true, true},
+		{"expired error", storedEntry("Bing", "b", entry.ErrorMinor, 31*time.Minute), false, false, false},
+		{"fresh bad translation", storedEntry("Bing", "c", entry.ErrorBadTranslation, 23*time.Hour), true, true, true},
+		{"expired success", storedEntry("Bing", "d", entry.ErrorNone, 366*24*time.Hour), false, false, true},
+		{"success that never expires", storedEntry("Google", "e", entry.ErrorNone, 10*365*24*time.Hour), true, false, true},
+	}
+	for _, tt := range tests {
+		for _, warm := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/memory=%v", tt.name, warm), func(t *testing.T) {
+				store := newMemoryStore()
+				store.Save(tt.stored)
+
+				c, err := newTieredCache(store, testPolicy, []string{"Bing", "Google"})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// Entry read from memory should carry the same timestamp as stored one
+				if warm {
+					c.memory(tt.stored.Service).Add(tt.stored.Key, tt.stored)
+				}
+
+				req := translator.Request{Text: tt.stored.Text, From: "ja", To: "en"}
+
+				_, found, err := c.Get(tt.stored.Service, req)
+				if found != tt.wantFound {
+					t.Errorf("Get() found = %v, want %v", found, tt.wantFound)
+				}
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+				}
+
+				if _, kept := store.entries[tt.stored.Key]; kept != tt.wantKept {
+					t.Errorf("entry kept = %v, want %v", kept, tt.wantKept)
+				}
+			})
+		}
+	}
+}
+
+func Test_tieredCache_GetAll(t *testing.T) {
+	store := newMemoryStore()
+	store.Save(storedEntry("Google", "a", entry.ErrorMinor, time.Minute))
+	store.Save(storedEntry("Bing", "a", entry.ErrorNone, time.Minute))
+	store.Save(storedEntry("Yandex", "a", entry.ErrorMinor, time.Hour))
+	store.Save(storedEntry("Bing", "b", entry.ErrorNone, time.Minute))
+
+	c, _ := newTieredCache(store, testPolicy, []string{"Google", "Bing", "Yandex"})
+
+	req := translator.Request{Text: "a", From: "ja", To: "en"}
+
+	for i := 0; i < 2; i++ {
+		got, err := c.GetAll(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Expired Yandex error is left out
+		if len(got) != 2 || got["Google"].Err == nil || got["Bing"].Err != nil {
+			t.Errorf("GetAll() = %+v", got)
+		}
+	}
+
+	// Second call is served from memory, except for Yandex which has nothing cached
+	if store.queries != 2 {
+		t.Errorf("store queried %d times, want 2", store.queries)
+	}
+}
+
+func Test_tieredCache_Served(t *testing.T) {
+	store := newMemoryStore()
+	store.Save(storedEntry("Google", "a", entry.ErrorNone, time.Minute))
+	store.Save(storedEntry("Bing", "a", entry.ErrorNone, time.Minute))
+
+	c, _ := newTieredCache(store, testPolicy, []string{"Google", "Bing"})
+	c.hits = newHitRecorder(store, time.Hour)
+
+	req := translator.Request{Text: "a", From: "ja", To: "en"}
+
+	if got, err := c.GetAll(req); err != nil || len(got) != 2 {
+		t.Fatalf("GetAll() = %+v, %v", got, err)
+	}
+
+	// Only the answer that was actually used counts as a read
+	c.Served("Bing", req)
+
+	if err := c.hits.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if hits := store.entries[memoryKey("Google", req)].Hits; hits != 0 {
+		t.Errorf("Google hits = %d, want 0", hits)
+	}
+
+	if hits := store.entries[memoryKey("Bing", req)].Hits; hits != 1 {
+		t.Errorf("Bing hits = %d, want 1", hits)
+	}
+}
+
+func Test_tieredCache_Context(t *testing.T) {
+	store := newMemoryStore()
+
+	c, _ := newTieredCache(store, testPolicy, []string{"LLM"})
+
+	context := []translator.Line{{Text: "先輩", Translation: "Senpai"}}
+
+	// Session isn't part of the key, same text in another session finds it
+	req := translator.Request{Text: "おはよう", From: "ja", To: "en", Session: "a"}
+	if err := c.PutResult("LLM", req, Cached{Translation: "Good morning", Context: context}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read back from store rather than memory
+	c, _ = newTieredCache(store, testPolicy, []string{"LLM"})
+
+	got, err := c.GetAll(translator.Request{Text: "おはよう", From: "ja", To: "en", Session: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got["LLM"].Context, context) {
+		t.Errorf("GetAll() context = %+v, want %+v", got["LLM"].Context, context)
+	}
+}
+
+func Test_tieredCache_Rollback(t *testing.T) {
+	store := newMemoryStore()
+
+	c, _ := newTieredCache(store, testPolicy, []string{"Bing"})
+
+	req := translator.Request{Text: "a", From: "ja", To: "en"}
+
+	c.Put("Bing", req, "good", nil)
+	c.Report("Bing", req)
+
+	if _, found, err := c.Get("Bing", req); !found || err == nil {
+		t.Fatalf("Get() after report found = %v, err = %v, want cached error", found, err)
+	}
+
+	versions, err := c.History("Bing", req)
+	if err != nil || len(versions) != 1 || versions[0].Translation != "good" || versions[0].Reason != entry.ReasonReport {
+		t.Fatalf("History() = %+v, %v", versions, err)
+	}
+
+	if _, err := c.Rollback("Bing", req, versions[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if out, _, err := c.Get("Bing", req); out != "good" || err != nil {
+		t.Errorf("Get() after rollback = %q, %v, want %q", out, err, "good")
+	}
+
+	if _, err := c.Rollback("Bing", req, 100); err == nil {
+		t.Error("Rollback() to missing version succeeded")
+	}
+}
+
+func Test_tieredCache_Evict(t *testing.T) {
+	newStore := func() *memoryStore {
+		store := newMemoryStore()
+
+		for i, text := range []string{"a", "b", "c", "d", "e", "f"} {
+			e := storedEntry("Bing", text, entry.ErrorNone, time.Minute)
+			e.LastAccess = time.Now().Add(time.Duration(i) * time.Second)
+			store.Save(e)
+		}
+
+		pinned := store.entries[entry.Key{Service: "Bing", From: "ja", To: "en", Text: "a"}]
+		pinned.Pinned = true
+		store.Save(pinned)
+
+		manual := store.entries[entry.Key{Service: "Bing", From: "ja", To: "en", Text: "b"}]
+		manual.Manual = true
+		store.Save(manual)
+
+		store.Save(storedEntry("Bing", "g", entry.ErrorMinor, time.Minute))
+
+		return store
+	}
+
+	tests := []struct {
+		name     string
+		maxRows  int64
+		maxBytes int64
+		want     int64
+		wantLeft []string
+	}{
+		{"no limits", 0, 0, 0, []string{"a", "b", "c", "d", "e", "f", "g"}},
+		{"under limits", 10, 1000, 0, []string{"a", "b", "c", "d", "e", "f", "g"}},
+		{"too many rows", 5, 0, 2, []string{"a", "b", "e", "f", "g"}},
+		{"too big", 0, 450, 3, []string{"a", "b", "f", "g"}},
+		{"can't go lower", 1, 0, 4, []string{"a", "b", "g"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newStore()
+			c, _ := newTieredCache(store, testPolicy, []string{"Bing"})
+
+			got, err := c.Evict(tt.maxRows, tt.maxBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Evict() = %d, want %d", got, tt.want)
+			}
+
+			var left []string
+			for k := range store.entries {
+				left = append(left, k.Text)
+			}
+			sort.Strings(left)
+
+			if fmt.Sprint(left) != fmt.Sprint(tt.wantLeft) {
+				t.Errorf("entries left = %v, want %v", left, tt.wantLeft)
+			}
+		})
+	}
+}
+
+func Test_tieredCache_Preload(t *testing.T) {
+	store := newMemoryStore()
+
+	for i, text := range []string{"a", "b", "c"} {
+		e := storedEntry("Bing", text, entry.ErrorNone, time.Minute)
+		e.Hits = int64(10 - i)
+		store.Save(e)
+	}
+	store.Save(storedEntry("Bing", "d", entry.ErrorMinor, time.Minute))
+
+	c, _ := newTieredCache(store, testPolicy, []string{"Bing", "Google"})
+
+	n, err := c.Preload(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 {
+		t.Errorf("Preload() = %d, want 2", n)
+	}
+
+	for text, want := range map[string]bool{"a": true, "b": true, "c": false, "d": false} {
+		if got := c.memory("Bing").Contains(entry.Key{Service: "Bing", From: "ja", To: "en", Text: text}); got != want {
+			t.Errorf("%q in memory = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func Test_tieredCache_PutError(t *testing.T) {
+	store := newMemoryStore()
+
+	c, err := newTieredCache(store, testPolicy, []string{"Bing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := translator.Request{Text: "a", From: "ja", To: "en"}
+
+	c.Put("Bing", req, "", fmt.Errorf("connection refused"))
+	c.Put("Bing", translator.Request{Text: "b", From: "ja", To: "en"}, "", translator.BadTranslationError{Input: "b"})
+
+	if code := store.entries[memoryKey("Bing", req)].ErrorCode; code != entry.ErrorMinor {
+		t.Errorf("stored error code = %v, want %v", code, entry.ErrorMinor)
+	}
+
+	// Read it back through a fresh cache so it has to come from the store
+	c, _ = newTieredCache(store, testPolicy, []string{"Bing"})
+
+	for i := 0; i < 3; i++ {
+		if _, found, err := c.Get("Bing", req); !found || err == nil {
+			t.Fatalf("cached error not retained on read %d: found = %v, err = %v", i, found, err)
+		}
+	}
+}
+
+func Test_tieredCache_PurgeExpired(t *testing.T) {
+	store := newMemoryStore()
+	store.Save(storedEntry("Bing", "a", entry.ErrorMinor, 10*time.Minute))
+	store.Save(storedEntry("Bing", "b", entry.ErrorMinor, time.Hour))
+	store.Save(storedEntry("Bing", "c", entry.ErrorBadTranslation, 2*time.Hour))
+	store.Save(storedEntry("Bing", "d", entry.ErrorNone, 2*365*24*time.Hour))
+
+	c, _ := newTieredCache(store, testPolicy, []string{"Bing"})
+
+	purged, err := c.PurgeExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only expired errors are purged, successes are left alone
+	if purged != 1 {
+		t.Errorf("PurgeExpired() = %d, want 1", purged)
+	}
+
+	if len(store.entries) != 3 {
+		t.Errorf("%d entries left, want 3", len(store.entries))
+	}
+}
+
+// brokenStore fails every database operation
+type brokenStore struct {
+	*memoryStore
+}
+
+func (brokenStore) Load(key entry.Key) (entry.Entry, bool, error) {
+	return entry.Entry{}, false, fmt.Errorf("database is locked")
+}
+
+func (brokenStore) LoadAll(services []string, from, to, text string) ([]entry.Entry, error) {
+	return nil, fmt.Errorf("database is locked")
+}
+
+func (brokenStore) Save(e entry.Entry) error {
+	return fmt.Errorf("database is locked")
+}
+
+func (brokenStore) SaveBatch(entries []entry.Entry) error {
+	return fmt.Errorf("database is locked")
+}
+
+func Test_tieredCache_BrokenStore(t *testing.T) {
+	c, _ := newTieredCache(brokenStore{newMemoryStore()}, testPolicy, []string{"Bing"})
+
+	req := translator.Request{Text: "a", From: "ja", To: "en"}
+
+	if _, found, err := c.Get("Bing", req); found || !IsBackendError(err) {
+		t.Errorf("Get() found = %v, err = %v, want backend error", found, err)
+	}
+
+	if err := c.Put("Bing", req, "A", nil); !IsBackendError(err) {
+		t.Errorf("Put() err = %v, want backend error", err)
+	}
+
+	// Memory cache keeps serving while database is down
+	if out, found, err := c.Get("Bing", req); !found || err != nil || out != "A" {
+		t.Errorf("Get() = %q, %v, %v, want result from memory", out, found, err)
+	}
+}
+
+func Test_tieredCache_WriteBehind(t *testing.T) {
+	store := newMemoryStore()
+	broken := brokenStore{store}
+
+	// Start with database down so nothing can be written
+	c, _ := newTieredCache(store, testPolicy, []string{"Bing"})
+	c.buffer = &writeBehind{store: broken, batchSize: 10, maxPending: 2, lock: &sync.Mutex{}, pending: make(map[entry.Key]entry.Entry), flushLock: &sync.Mutex{}}
+
+	a := translator.Request{Text: "a", From: "ja", To: "en"}
+	b := translator.Request{Text: "b", From: "ja", To: "en"}
+
+	if err := c.Put("Bing", a, "A", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put("Bing", b, "B", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Put("Bing", translator.Request{Text: "c", From: "ja", To: "en"}, "C", nil); !IsBackendError(err) {
+		t.Errorf("Put() into full buffer err = %v, want backend error", err)
+	}
+
+	if err := c.buffer.flush(); err == nil {
+		t.Error("flush() to broken store succeeded")
+	}
+
+	// Failed entries stay buffered and are still served after memory forgets them
+	c.memory("Bing").Remove(memoryKey("Bing", a))
+
+	if out, found, err := c.Get("Bing", a); !found || err != nil || out != "A" {
+		t.Errorf("Get() = %q, %v, %v, want buffered result", out, found, err)
+	}
+
+	// Database is back
+	c.buffer.store = store
+
+	if err := c.buffer.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.entries) != 2 || len(c.buffer.pending) != 0 {
+		t.Errorf("%d entries stored and %d pending after flush, want 2 and 0", len(store.entries), len(c.buffer.pending))
+	}
+}
+
+// slowStore holds up batch writes until released
+type slowStore struct {
+	*memoryStore
+
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (s slowStore) SaveBatch(entries []entry.Entry) error {
+	close(s.saving)
+	<-s.release
+
+	return s.memoryStore.SaveBatch(entries)
+}
+
+func Test_tieredCache_WriteBehindEdit(t *testing.T) {
+	store := slowStore{newMemoryStore(), make(chan struct{}), make(chan struct{})}
+
+	c, _ := newTieredCache(store, testPolicy, []string{"Bing"})
+	c.buffer = &writeBehind{store: store, batchSize: 10, maxPending: 10, lock: &sync.Mutex{}, pending: make(map[entry.Key]entry.Entry), flushLock: &sync.Mutex{}}
+
+	req := translator.Request{Text: "a", From: "ja", To: "en"}
+
+	if err := c.Put("Bing", req, "machine", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		if err := c.buffer.flush(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// Edit comes in while older entry is being written
+	<-store.saving
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		if err := c.Edit("Bing", req, "manual"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	close(store.release)
+	wg.Wait()
+
+	if e := store.entries[memoryKey("Bing", req)]; e.Translation != "manual" || !e.Manual {
+		t.Errorf("stored entry = %+v, want manual edit", e)
+	}
+}
+
+// slowReplaceStore holds up replacing entries until released
+type slowReplaceStore struct {
+	*memoryStore
+
+	replacing chan struct{}
+	release   chan struct{}
+}
+
+func (s slowReplaceStore) Replace(e entry.Entry, reason entry.Reason) error {
+	close(s.replacing)
+	<-s.release
+
+	return s.memoryStore.Replace(e, reason)
+}
+
+func Test_tieredCache_PutDuringEdit(t *testing.T) {
+	store := slowReplaceStore{newMemoryStore(), make(chan struct{}), make(chan struct{})}
+
+	c, _ := newTieredCache(store, testPolicy, []string{"Bing"})
+	c.buffer = &writeBehind{store: store, batchSize: 10, maxPending: 10, lock: &sync.Mutex{}, pending: make(map[entry.Key]entry.Entry), flushLock: &sync.Mutex{}}
+
+	req := translator.Request{Text: "a", From: "ja", To: "en"}
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		if err := c.Edit("Bing", req, "manual"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// Engine result comes in while edit is being stored
+	<-store.replacing
+
+	put := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(put)
+
+		if err := c.Put("Bing", req, "machine", nil); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	select {
+	case <-put:
+		t.Error("Put() finished while edit was still being stored")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(store.release)
+	wg.Wait()
+
+	if err := c.buffer.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Put waited for the edit so it's newer, memory and store have to agree on that
+	stored := store.entries[memoryKey("Bing", req)]
+	if stored.Translation != "machine" {
+		t.Errorf("stored entry = %+v, want result put after edit", stored)
+	}
+
+	if got, _, _ := c.Get("Bing", req); got != stored.Translation {
+		t.Errorf("Get() = %q, stored %q", got, stored.Translation)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want entry.ErrorCode
+	}{
+		{"plain", fmt.Errorf("something"), entry.ErrorMinor},
+		{"bad translation", translator.BadTranslationError{}, entry.ErrorBadTranslation},
+		{"rate limited", translator.RateLimitedError{RetryAfter: time.Minute}, entry.ErrorRateLimited},
+		{"auth failed", translator.AuthFailedError{}, entry.ErrorAuthFailed},
+		{"unsupported", translator.UnsupportedError{}, entry.ErrorUnsupported},
+		{"wrapped transient", fmt.Errorf("request: %w", translator.TransientError{Err: fmt.Errorf("timeout")}), entry.ErrorTransient},
+		{"blocked", translator.BlockedError{}, entry.ErrorBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorCode(tt.err); got != tt.want {
+				t.Errorf("ErrorCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
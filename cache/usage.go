@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+// How often collected reads are written to backend
+const hitFlushInterval = time.Minute
+
+// hitRecorder counts reads in memory so lookups don't have to write anything,
+// counts are added to backend in one transaction every once in a while
+type hitRecorder struct {
+	store Store
+
+	lock *sync.Mutex
+	hits map[entry.Key]entry.Hits
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newHitRecorder(store Store, interval time.Duration) *hitRecorder {
+	r := &hitRecorder{
+		store: store,
+		lock:  &sync.Mutex{},
+		hits:  make(map[entry.Key]entry.Hits),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	go r.run(interval)
+
+	return r
+}
+
+func (r *hitRecorder) add(key entry.Key) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	h := r.hits[key]
+	h.Count++
+	h.Last = time.Now().UTC()
+
+	r.hits[key] = h
+}
+
+func (r *hitRecorder) run(interval time.Duration) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.flush(); err != nil {
+				log.Warnf("Failed to record cache hits: %v", err)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// flush writes collected reads, they are dropped if that fails since losing some counts doesn't matter much
+func (r *hitRecorder) flush() error {
+	r.lock.Lock()
+	hits := r.hits
+	r.hits = make(map[entry.Key]entry.Hits)
+	r.lock.Unlock()
+
+	if err := r.store.RecordHits(hits); err != nil {
+		return &BackendError{Op: "record hits", Err: err}
+	}
+
+	return nil
+}
+
+func (r *hitRecorder) close() error {
+	close(r.stop)
+	<-r.done
+
+	return r.flush()
+}
+
+// Evict deletes least recently used translations until there are no more than maxRows of them
+// and they take no more than maxBytes, zero means no limit. Manual and pinned translations are kept
+func (c *tieredCache) Evict(maxRows, maxBytes int64) (int64, error) {
+	if maxRows <= 0 && maxBytes <= 0 {
+		return 0, nil
+	}
+
+	// Recent reads have to be known before deciding what is least recently used
+	if c.hits != nil {
+		if err := c.hits.flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	count, err := c.store.Count()
+	if err != nil {
+		return 0, err
+	}
+
+	var excess int64
+
+	if maxRows > 0 && count > maxRows {
+		excess = count - maxRows
+	}
+
+	if maxBytes > 0 && count > 0 {
+		size, err := c.store.Size()
+		if err != nil {
+			return 0, err
+		}
+
+		// Rows are different sizes, average is close enough and next run takes care of the rest
+		if size > maxBytes {
+			perRow := size / count
+			if perRow < 1 {
+				perRow = 1
+			}
+
+			if n := (size-maxBytes)/perRow + 1; n > excess {
+				excess = n
+			}
+		}
+	}
+
+	if excess == 0 {
+		return 0, nil
+	}
+
+	evicted, err := c.store.Evict(excess)
+	if err != nil {
+		return evicted, &BackendError{Op: "evict", Err: err}
+	}
+
+	log.Infof("Evicted %d least recently used translations", evicted)
+
+	return evicted, nil
+}
+
+func (c *tieredCache) Hot(bucketName string, limit int) ([]entry.Entry, error) {
+	entries, err := c.store.Hot(bucketName, limit)
+	if err != nil {
+		return nil, &BackendError{Op: "hot", Err: err}
+	}
+
+	return entries, nil
+}
+
+func (c *tieredCache) Preload(perBucket int) (int, error) {
+	if perBucket > memoryCacheSize {
+		perBucket = memoryCacheSize
+	}
+
+	var loaded int
+
+	for _, name := range c.translators {
+		entries, err := c.store.Hot(name, perBucket)
+		if err != nil {
+			return loaded, &BackendError{Op: "preload", Err: err}
+		}
+
+		mem := c.memory(name)
+
+		// Least used go in first so they are the first to be pushed out
+		for i := len(entries) - 1; i >= 0; i-- {
+			// Requests that came in meanwhile already put something fresher there
+			if !mem.Contains(entries[i].Key) {
+				mem.Add(entries[i].Key, entries[i])
+				loaded++
+			}
+		}
+	}
+
+	return loaded, nil
+}
+
+func (c *tieredCache) Pin(bucketName string, req translator.Request, pinned bool) error {
+	key := memoryKey(bucketName, req)
+
+	found, err := c.store.SetPinned(key, pinned)
+	if err != nil {
+		return &BackendError{Op: "pin", Err: err}
+	}
+
+	if !found {
+		return fmt.Errorf("%s %q is not cached", bucketName, req.Text)
+	}
+
+	// Memory copy doesn't know about the pin
+	c.memory(bucketName).Remove(key)
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+)
+
+// Attempts made to store what's left in buffer when shutting down
+const finalFlushAttempts = 3
+
+// writeBehind collects entries in memory and stores them in batches on a timer or when enough pile up.
+// Entries that fail to store are kept and retried on next flush
+type writeBehind struct {
+	store Store
+
+	interval   time.Duration
+	batchSize  int
+	maxPending int
+
+	lock    *sync.Mutex
+	pending map[entry.Key]entry.Entry
+
+	// Held from taking pending entries until they're stored, so writes that replace entries can't land before older buffered ones
+	flushLock *sync.Mutex
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newWriteBehind(store Store, interval time.Duration, batchSize, maxPending int) *writeBehind {
+	w := &writeBehind{
+		store:      store,
+		interval:   interval,
+		batchSize:  batchSize,
+		maxPending: maxPending,
+		lock:       &sync.Mutex{},
+		pending:    make(map[entry.Key]entry.Entry),
+		flushLock:  &sync.Mutex{},
+		kick:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+// add queues entry for writing, newer entry replaces pending one with the same key
+func (w *writeBehind) add(e entry.Entry) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if _, queued := w.pending[e.Key]; !queued && len(w.pending) >= w.maxPending {
+		return fmt.Errorf("write buffer is full with %d entries", len(w.pending))
+	}
+
+	w.pending[e.Key] = e
+
+	if len(w.pending) >= w.batchSize {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// get returns entry that is still waiting to be written
+func (w *writeBehind) get(key entry.Key) (entry.Entry, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	e, ok := w.pending[key]
+
+	return e, ok
+}
+
+func (w *writeBehind) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.kick:
+		case <-w.stop:
+			return
+		}
+
+		if err := w.flush(); err != nil {
+			log.Errorf("Failed to write buffered translations, will retry: %v", err)
+		}
+	}
+}
+
+// flush writes everything pending, entries that failed are put back unless something newer replaced them
+func (w *writeBehind) flush() error {
+	w.flushLock.Lock()
+	defer w.flushLock.Unlock()
+
+	return w.flushLocked()
+}
+
+// flushed writes everything pending and runs fn before another flush can start, so fn isn't overwritten by older buffered entries
+func (w *writeBehind) flushed(fn func() error) error {
+	w.flushLock.Lock()
+	defer w.flushLock.Unlock()
+
+	if err := w.flushLocked(); err != nil {
+		return err
+	}
+
+	return fn()
+}
+
+func (w *writeBehind) flushLocked() error {
+	w.lock.Lock()
+	batch := make([]entry.Entry, 0, len(w.pending))
+	for _, e := range w.pending {
+		batch = append(batch, e)
+	}
+	w.pending = make(map[entry.Key]entry.Entry)
+	w.lock.Unlock()
+
+	for len(batch) > 0 {
+		n := w.batchSize
+		if n > len(batch) {
+			n = len(batch)
+		}
+
+		if err := w.store.SaveBatch(batch[:n]); err != nil {
+			w.requeue(batch)
+			return &BackendError{Op: "save", Err: err}
+		}
+
+		batch = batch[n:]
+	}
+
+	return nil
+}
+
+func (w *writeBehind) requeue(entries []entry.Entry) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, e := range entries {
+		if _, replaced := w.pending[e.Key]; !replaced {
+			w.pending[e.Key] = e
+		}
+	}
+}
+
+// close stops background flushing and writes whatever is left
+func (w *writeBehind) close() error {
+	close(w.stop)
+	<-w.done
+
+	var err error
+
+	for i := 0; i < finalFlushAttempts; i++ {
+		if err = w.flush(); err == nil {
+			return nil
+		}
+
+		log.Warnf("Failed to write buffered translations on shutdown: %v", err)
+		time.Sleep(time.Second)
+	}
+
+	w.lock.Lock()
+	lost := len(w.pending)
+	w.lock.Unlock()
+
+	return fmt.Errorf("lost %d buffered translations: %v", lost, err)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"export": {"Write cached translations to a JSONL or TSV file", exportCommand},
+	"import": {"Load cached translations from a JSONL or TSV file", importCommand},
+
+	"migrate-cache": {"Copy every cached translation from one database to another", migrateCacheCommand},
+	"backup":        {"Write a snapshot of the sqlite cache, safe to run while server is running", backupCommand},
+
+	"reprocess": {"Clean up cached translations again with current rules without contacting translators", reprocessCommand},
+
+	"history":  {"List previous translations of a cached line", historyCommand},
+	"rollback": {"Restore previous translation of a cached line, use /admin/rollback while server is running", rollbackCommand},
+}
+
+func runCommand(name string, args []string) error {
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return nil
+	}
+
+	cmd, found := commands[name]
+	if !found {
+		printUsage()
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	return cmd.run(args)
+}
+
+func printUsage() {
+	var names []string
+	for k := range commands {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\nRuns translation server when no command is given\n\nCommands:\n", os.Args[0])
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, commands[name].usage)
+	}
+}
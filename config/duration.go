@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Never is used as a duration for things that should never expire
+const Never Duration = -1
+
+// Duration is a time.Duration that can be written as "30m", "12h", "30d" or "never" in config
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	s := strings.ToLower(strings.TrimSpace(string(text)))
+
+	switch {
+	case s == "":
+		*d = 0
+		return nil
+	case s == "never":
+		*d = Never
+		return nil
+	case strings.HasSuffix(s, "d"):
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days < 1 {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+
+		*d = Duration(time.Duration(days) * 24 * time.Hour)
+		return nil
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	if v <= 0 {
+		return fmt.Errorf("invalid duration %q, use \"never\" to keep forever", s)
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	switch {
+	case d == 0:
+		return []byte(""), nil
+	case d == Never:
+		return []byte("never"), nil
+	case time.Duration(d)%(24*time.Hour) == 0:
+		return []byte(fmt.Sprintf("%dd", time.Duration(d)/(24*time.Hour))), nil
+	}
+
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDuration_UnmarshalText(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    Duration
+		wantErr bool
+	}{
+		{"empty", "", 0, false},
+		{"never", "never", Never, false},
+		{"minutes", "30m", Duration(30 * time.Minute), false},
+		{"days", "30d", Duration(30 * 24 * time.Hour), false},
+		{"negative", "-1h", 0, true},
+		{"garbage", "soon", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Duration
+
+			err := d.UnmarshalText([]byte(tt.text))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalText() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if d != tt.want {
+				t.Errorf("UnmarshalText() = %v, want %v", d, tt.want)
+			}
+		})
+	}
+}
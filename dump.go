@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"gitgud.io/softashell/comfy-translator/cache/dump"
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+)
+
+const dumpBatchSize = 1000
+
+func exportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "jsonl", "Output format, jsonl or tsv")
+	output := fs.String("o", "-", "Output file, - for stdout")
+	service := fs.String("service", "", "Only export entries from this translator")
+	since := fs.String("since", "", "Only export entries stored at or after this date (2006-01-02 or RFC3339)")
+	until := fs.String("until", "", "Only export entries stored before this date (2006-01-02 or RFC3339)")
+	errorFilter := fs.String("errors", "all", "Which entries to export by error status: all, only or none")
+	fs.Parse(args)
+
+	f, err := dump.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	var filter dump.Filter
+
+	filter.Service = *service
+
+	if filter.Since, err = dump.ParseTime(*since); err != nil {
+		return errors.Wrap(err, "invalid -since")
+	}
+
+	if filter.Until, err = dump.ParseTime(*until); err != nil {
+		return errors.Wrap(err, "invalid -until")
+	}
+
+	if filter.Errors, err = dump.ParseErrorFilter(*errorFilter); err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		out = file
+	}
+
+	c, err := openCache()
+	if err != nil {
+		return errors.Wrap(err, "failed to open cache")
+	}
+	defer c.Close()
+
+	w := dump.NewWriter(out, f)
+
+	var after entry.Key
+	var total, written int
+
+	for {
+		entries, err := c.Scan(after, dumpBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(entries) < 1 {
+			break
+		}
+
+		for _, e := range entries {
+			if !filter.Match(e) {
+				continue
+			}
+
+			if err := w.Write(e); err != nil {
+				return err
+			}
+
+			written++
+		}
+
+		total += len(entries)
+		after = entries[len(entries)-1].Key
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	log.Infof("Exported %d of %d entries", written, total)
+
+	return nil
+}
+
+func importCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "jsonl", "Input format, jsonl or tsv")
+	input := fs.String("i", "-", "Input file, - for stdin")
+	strategy := fs.String("strategy", "keep", "What to do with entries that are already cached: keep, overwrite or newest")
+	fs.Parse(args)
+
+	f, err := dump.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	s, err := entry.ParseMergeStrategy(*strategy)
+	if err != nil {
+		return err
+	}
+
+	in := io.Reader(os.Stdin)
+	if *input != "-" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		in = file
+	}
+
+	c, err := openCache()
+	if err != nil {
+		return errors.Wrap(err, "failed to open cache")
+	}
+	defer c.Close()
+
+	r := dump.NewReader(in, f)
+
+	var batch []entry.Entry
+	var total, changed int
+
+	flush := func() error {
+		n, err := c.Import(batch, s)
+		if err != nil {
+			return err
+		}
+
+		total += len(batch)
+		changed += n
+		batch = batch[:0]
+
+		log.Infof("Imported %d entries, %d changed", total, changed)
+
+		return nil
+	}
+
+	for {
+		e, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		batch = append(batch, e)
+
+		if len(batch) >= dumpBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(batch) > 0 {
+		return flush()
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitgud.io/softashell/comfy-translator/cache"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+// entryRequest points at a single cached translation in /admin requests
+type entryRequest struct {
+	translator.Request
+
+	Service     string `json:"service"`
+	Translation string `json:"translation,omitempty"`
+	Version     int64  `json:"version,omitempty"`
+	Pinned      bool   `json:"pinned,omitempty"`
+}
+
+type versionResponse struct {
+	ID          int64     `json:"id"`
+	Translation string    `json:"translation"`
+	ErrorCode   string    `json:"errorCode"`
+	ErrorText   string    `json:"errorText,omitempty"`
+	Time        time.Time `json:"time"`
+	Reason      string    `json:"reason"`
+	Replaced    time.Time `json:"replaced"`
+}
+
+func readEntryRequest(w http.ResponseWriter, r *http.Request) (entryRequest, bool) {
+	var req entryRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+
+	if req.Service == "" || req.Text == "" {
+		http.Error(w, "service and text are required", http.StatusBadRequest)
+		return req, false
+	}
+
+	return req, true
+}
+
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := readEntryRequest(w, r)
+	if !ok {
+		return
+	}
+
+	versions, err := c.History(req.Service, req.Request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]versionResponse, len(versions))
+	for i, v := range versions {
+		out[i] = versionResponse{
+			ID:          v.ID,
+			Translation: v.Translation,
+			ErrorCode:   v.ErrorCode.String(),
+			ErrorText:   v.ErrorText,
+			Time:        v.Timestamp,
+			Reason:      string(v.Reason),
+			Replaced:    v.Replaced,
+		}
+	}
+
+	writeJSON(w, out)
+}
+
+func rollbackHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := readEntryRequest(w, r)
+	if !ok {
+		return
+	}
+
+	e, err := c.Rollback(req.Service, req.Request, req.Version)
+	if err != nil {
+		writeCacheError(w, err)
+		return
+	}
+
+	memo.Purge()
+
+	writeJSON(w, map[string]string{"translation": e.Translation})
+}
+
+func editHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := readEntryRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.Edit(req.Service, req.Request, req.Translation); err != nil {
+		writeCacheError(w, err)
+		return
+	}
+
+	memo.Purge()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func reportHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := readEntryRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.Report(req.Service, req.Request); err != nil {
+		writeCacheError(w, err)
+		return
+	}
+
+	memo.Purge()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func pinHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := readEntryRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.Pin(req.Service, req.Request, req.Pinned); err != nil {
+		writeCacheError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeCacheError(w http.ResponseWriter, err error) {
+	if cache.IsBackendError(err) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusNotFound)
+}
+
+func entryFlags(fs *flag.FlagSet) *entryRequest {
+	req := &entryRequest{}
+
+	fs.StringVar(&req.Service, "service", "", "Translator that cached the translation")
+	fs.StringVar(&req.From, "from", "ja", "Source language")
+	fs.StringVar(&req.To, "to", "en", "Target language")
+	fs.StringVar(&req.Text, "text", "", "Original text")
+
+	return req
+}
+
+func historyCommand(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	req := entryFlags(fs)
+	fs.Parse(args)
+
+	if req.Service == "" || req.Text == "" {
+		fs.Usage()
+		return fmt.Errorf("both -service and -text are required")
+	}
+
+	c, err := openCache()
+	if err != nil {
+		return errors.Wrap(err, "failed to open cache")
+	}
+	defer c.Close()
+
+	versions, err := c.History(req.Service, req.Request)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tREPLACED\tREASON\tSTORED\tTRANSLATION")
+
+	for _, v := range versions {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", v.ID, v.Replaced.Format(time.RFC3339), v.Reason, v.Timestamp.Format(time.RFC3339), v.Translation)
+	}
+
+	return tw.Flush()
+}
+
+func rollbackCommand(args []string) error {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	req := entryFlags(fs)
+	fs.Int64Var(&req.Version, "version", 0, "Version to restore, as listed by history command")
+	fs.Parse(args)
+
+	if req.Service == "" || req.Text == "" || req.Version == 0 {
+		fs.Usage()
+		return fmt.Errorf("-service, -text and -version are required")
+	}
+
+	c, err := openCache()
+	if err != nil {
+		return errors.Wrap(err, "failed to open cache")
+	}
+	defer c.Close()
+
+	e, err := c.Rollback(req.Service, req.Request, req.Version)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %q\n", e.Translation)
+
+	return nil
+}
@@ -5,7 +5,9 @@ import (
 	"os"
 	"os/signal"
 	"sort"
+	"strings"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -13,13 +15,24 @@ import (
 	"gitgud.io/softashell/comfy-translator/config"
 	"gitgud.io/softashell/comfy-translator/translator"
 	"gitgud.io/softashell/comfy-translator/translator/bing"
+	"gitgud.io/softashell/comfy-translator/translator/deepl"
+	"gitgud.io/softashell/comfy-translator/translator/exec"
+	"gitgud.io/softashell/comfy-translator/translator/generic"
 	"gitgud.io/softashell/comfy-translator/translator/google"
+	"gitgud.io/softashell/comfy-translator/translator/libretranslate"
+	"gitgud.io/softashell/comfy-translator/translator/llm"
+	"gitgud.io/softashell/comfy-translator/translator/sugoi"
 	"gitgud.io/softashell/comfy-translator/translator/yandex"
 )
 
 var (
 	c           cache.Cache
+	maintenance *cache.Maintenance
+	backups     *cache.Backups
 	q           *Queue
+	memo        *AnswerMemo
+	sessions    *Sessions
+	health      *Health
 	conf        *config.Config
 	translators []translator.Translator
 )
@@ -32,6 +45,22 @@ func main() {
 		log.SetLevel(log.DebugLevel)
 	}
 
+	var err error
+
+	conf = config.NewConfig()
+	err = conf.Load("comfy-translator.toml")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
 	sigs := make(chan os.Signal, 1)
 	done := make(chan bool, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
@@ -43,26 +72,28 @@ func main() {
 		done <- true
 	}()
 
-	var err error
-
-	conf = config.NewConfig()
-	err = conf.Load("comfy-translator.toml")
+	c, err = openCache()
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("Failed to initialize translation cache: %v", err)
 	}
+	defer c.Close()
 
-	var translators []string
-	for k := range conf.Translator {
-		translators = append(translators, k)
+	if conf.Database.Preload > 0 {
+		go preloadCache(conf.Database.Preload)
 	}
 
-	c, err = cache.NewCache(conf, translators)
-	if err != nil {
-		log.Fatalf("Failed to initialize translation cache: %v", err)
-	}
-	defer c.Close()
+	maintenance = cache.NewMaintenance(c, conf)
+	maintenance.Start()
+	defer maintenance.Stop()
+
+	backups = cache.NewBackups(c, conf)
+	backups.Start()
+	defer backups.Stop()
 
 	q = NewQueue()
+	memo = NewAnswerMemo(answerMemoSize, answerMemoTTL)
+	sessions = NewSessions(conf.Sessions.Size, conf.Sessions.Lines, conf.Sessions.Idle.Duration())
+	health = NewHealth()
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -77,18 +108,116 @@ func main() {
 		"addr": listenAddr,
 	}).Info("Ready to accept connections")
 
-	go ServeComfyRPC(listenAddr)
+	go ServeComfyRPC(conf.Host, port)
 
 	<-done
 }
 
-func startTranslators() {
+func openCache() (cache.Cache, error) {
+	var translators []string
+	for k := range conf.Translator {
+		translators = append(translators, k)
+	}
+
+	return cache.NewCache(conf, translators)
+}
+
+// preloadCache warms up memory cache so popular lines don't have to hit database after restart
+func preloadCache(perBucket int) {
+	start := time.Now()
+
+	n, err := c.Preload(perBucket)
+	if err != nil {
+		log.Warnf("Failed to preload cache: %v", err)
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"time": time.Since(start),
+	}).Infof("Preloaded %d translations into memory", n)
+}
+
+// Most read translations aren't split by language pair, this many times more are looked through to find enough examples
+const exampleLookahead = 10
+
+// cacheExamples gives translators their previous translations to learn from, manually edited ones come first
+func cacheExamples(service, from, to string, limit int) ([]translator.Example, error) {
+	entries, err := c.Hot(service, limit*exampleLookahead)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Manual && !entries[j].Manual
+	})
+
+	var out []translator.Example
+
+	for _, e := range entries {
+		if e.From != from || e.To != to {
+			continue
+		}
+
+		out = append(out, translator.Example{Text: e.Text, Translation: e.Translation})
+		if len(out) >= limit {
+			break
+		}
+	}
+
+	return out, nil
+}
+
+// newTranslators returns every known translation engine, none of them are started yet
+func newTranslators() []translator.Translator {
 	t := []translator.Translator{
 		google.New(),
-		bing.New(),   // FIXME: Bing starts refusing connection pretty randomly and I can't tell what it doesn't like
+		bing.New(),   // FIXME: Without a key Bing starts refusing connection pretty randomly and I can't tell what it doesn't like
 		yandex.New(), // Pretty bad quality
+		deepl.New(),
+		llm.New(),
+		sugoi.New(), // Offline, only ja to en
+		libretranslate.New(),
 	}
 
+	builtin := make(map[string]bool)
+	for i := range t {
+		builtin[t[i].Name()] = true
+	}
+
+	// Engines described entirely in config, sorted so they always come in the same order
+	var names []string
+	for name, tc := range conf.Translator {
+		if len(tc.Type) < 1 {
+			continue
+		}
+
+		if builtin[name] {
+			log.Errorf("%s: Can't be %s, name is taken by a built in engine", name, tc.Type)
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch typ := strings.ToLower(conf.Translator[name].Type); typ {
+		case generic.Type:
+			t = append(t, generic.New(name))
+		case exec.Type:
+			t = append(t, exec.New(name))
+		default:
+			log.Errorf("%s: Unknown translator type %q", name, typ)
+		}
+	}
+
+	return t
+}
+
+func startTranslators() {
+	t := newTranslators()
+
 	log.Info("Starting translation engines")
 
 	for i := range t {
@@ -99,6 +228,10 @@ func startTranslators() {
 			log.Errorf("Couldn't find config for %q", name)
 		}
 
+		if u, ok := t[i].(translator.ExampleUser); ok {
+			u.UseExamples(cacheExamples)
+		}
+
 		if conf.Enabled {
 			log.Infof("%s: Starting", name)
 			err := t[i].Start(conf)
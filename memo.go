@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+const (
+	answerMemoSize = 2000
+	answerMemoTTL  = time.Minute
+)
+
+// AnswerMemo remembers final translations for a short while so lines repeated
+// back to back don't need to go through cache lookups for every engine again
+type AnswerMemo struct {
+	ttl   time.Duration
+	items *lru.Cache
+
+	now  func() time.Time
+	lock *sync.Mutex
+}
+
+type memoAnswer struct {
+	out     string
+	source  string
+	expires time.Time
+}
+
+func NewAnswerMemo(size int, ttl time.Duration) *AnswerMemo {
+	items, _ := lru.New(size)
+
+	return &AnswerMemo{
+		ttl:   ttl,
+		items: items,
+		now:   time.Now,
+		lock:  &sync.Mutex{},
+	}
+}
+
+func (m *AnswerMemo) Get(req translator.Request) (string, string, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	item, ok := m.items.Get(req)
+	if !ok {
+		return "", "", false
+	}
+
+	a := item.(memoAnswer)
+	if m.now().After(a.expires) {
+		m.items.Remove(req)
+		return "", "", false
+	}
+
+	return a.out, a.source, true
+}
+
+func (m *AnswerMemo) Add(req translator.Request, out, source string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.items.Add(req, memoAnswer{out: out, source: source, expires: m.now().Add(m.ttl)})
+}
+
+// Purge forgets everything, used when cache contents are replaced
+func (m *AnswerMemo) Purge() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.items.Purge()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+func TestAnswerMemo(t *testing.T) {
+	m := NewAnswerMemo(10, time.Minute)
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	req := translator.Request{Text: "テスト", From: "ja", To: "en"}
+
+	if _, _, ok := m.Get(req); ok {
+		t.Error("Get() found answer in empty memo")
+	}
+
+	m.Add(req, "test", "Google")
+
+	if out, source, ok := m.Get(req); !ok || out != "test" || source != "Google" {
+		t.Errorf("Get() = %q, %q, %v", out, source, ok)
+	}
+
+	// Same text in another language pair is a different request
+	if _, _, ok := m.Get(translator.Request{Text: "テスト", From: "ja", To: "ru"}); ok {
+		t.Error("Get() found answer for wrong language pair")
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if _, _, ok := m.Get(req); ok {
+		t.Error("Get() returned expired answer")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+)
+
+type memoryStore struct {
+	entries []entry.Entry
+	failAt  int
+	imports int
+}
+
+func (m *memoryStore) Scan(after entry.Key, limit int) ([]entry.Entry, error) {
+	var out []entry.Entry
+
+	for _, e := range m.entries {
+		if after.Less(e.Key) && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+
+	return out, nil
+}
+
+func (m *memoryStore) Count() (int64, error) {
+	return int64(len(m.entries)), nil
+}
+
+func (m *memoryStore) Import(entries []entry.Entry, strategy entry.MergeStrategy) (int, error) {
+	m.imports++
+	if m.failAt > 0 && m.imports == m.failAt {
+		return 0, fmt.Errorf("connection lost")
+	}
+
+	m.entries = append(m.entries, entries...)
+	sort.Slice(m.entries, func(i, j int) bool {
+		return m.entries[i].Key.Less(m.entries[j].Key)
+	})
+
+	return len(entries), nil
+}
+
+func Test_migrateEntries(t *testing.T) {
+	src := &memoryStore{}
+	for i := 0; i < 25; i++ {
+		src.entries = append(src.entries, entry.Entry{
+			Key:         entry.Key{Service: "Google", From: "ja", To: "en", Text: fmt.Sprintf("%02d", i)},
+			Translation: fmt.Sprint(i),
+		})
+	}
+
+	dst := &memoryStore{failAt: 2}
+
+	var saved migrationState
+	save := func(s *migrationState) error {
+		saved = *s
+		return nil
+	}
+
+	state := migrationState{}
+	if err := migrateEntries(src, dst, entry.Overwrite, 10, &state, save, func() {}); err == nil {
+		t.Fatal("expected migration to fail")
+	}
+
+	if saved.Copied != 10 || saved.After.Text != "09" {
+		t.Fatalf("unexpected saved progress %+v", saved)
+	}
+
+	// Resume from saved progress
+	state = saved
+	if err := migrateEntries(src, dst, entry.Overwrite, 10, &state, save, func() {}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dst.entries) != len(src.entries) {
+		t.Errorf("copied %d entries, want %d", len(dst.entries), len(src.entries))
+	}
+
+	if state.Copied != 25 {
+		t.Errorf("state.Copied = %d, want 25", state.Copied)
+	}
+}
@@ -58,8 +58,8 @@ func joinWait(t *testing.T, q *Queue, req translator.Request, wg *sync.WaitGroup
 	if ch == nil {
 		t.Error("Didn't return channel")
 	}
+	wg.Add(1)
 	go func(chan string) {
-		wg.Add(1)
 		defer wg.Done()
 
 		out := <-ch
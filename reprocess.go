@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"gitgud.io/softashell/comfy-translator/cache"
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+func reprocessCommand(args []string) error {
+	fs := flag.NewFlagSet("reprocess", flag.ExitOnError)
+	service := fs.String("service", "", "Only reprocess entries from this translator")
+	force := fs.Bool("force", false, "Reprocess entries that were already cleaned up by current rules")
+	batchSize := fs.Int("batch", 1000, "Number of entries updated per transaction")
+	fs.Parse(args)
+
+	processors := make(map[string]translator.Translator)
+	for _, t := range newTranslators() {
+		if _, ok := t.(translator.PostProcessor); ok {
+			processors[t.Name()] = t
+		}
+	}
+
+	c, err := openCache()
+	if err != nil {
+		return errors.Wrap(err, "failed to open cache")
+	}
+	defer c.Close()
+
+	var after entry.Key
+	var scanned, changed int
+
+	for {
+		entries, err := c.Scan(after, *batchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(entries) < 1 {
+			break
+		}
+
+		after = entries[len(entries)-1].Key
+		scanned += len(entries)
+
+		var updated []entry.Entry
+
+		for _, e := range entries {
+			t, ok := processors[e.Service]
+			if !ok || e.Raw == "" || (*service != "" && e.Service != *service) {
+				continue
+			}
+
+			if e.PostProcess == t.(translator.PostProcessor).PostProcessVersion() && !*force {
+				continue
+			}
+
+			if n, ok := reprocessEntry(t, e); ok {
+				updated = append(updated, n)
+			}
+		}
+
+		if len(updated) > 0 {
+			if _, err := c.Import(updated, entry.Overwrite); err != nil {
+				return err
+			}
+
+			changed += len(updated)
+		}
+
+		log.Infof("Reprocessed %d entries, %d changed", scanned, changed)
+	}
+
+	log.Infof("Done, %d of %d entries changed", changed, scanned)
+
+	return nil
+}
+
+// reprocessEntry cleans up raw output with current rules, stored time is kept so expiry isn't affected
+func reprocessEntry(t translator.Translator, e entry.Entry) (entry.Entry, bool) {
+	req := translator.Request{Text: e.Text, From: e.From, To: e.To}
+
+	result := postProcess(t, req, e.Raw, nil)
+
+	n := e
+	n.Translation = result.Translation
+	n.PostProcess = result.PostProcess
+	n.ErrorCode = entry.ErrorNone
+	n.ErrorText = ""
+
+	if result.Err != nil {
+		n.ErrorCode = cache.ErrorCode(result.Err)
+		n.ErrorText = result.Err.Error()
+	}
+
+	return n, n != e
+}
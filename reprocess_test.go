@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/cache/entry"
+	"gitgud.io/softashell/comfy-translator/translator/google"
+)
+
+func TestReprocessEntry(t *testing.T) {
+	g := google.New()
+
+	stored := entry.Entry{
+		Key:         entry.Key{Service: "Google", From: "ja", To: "en", Text: "できない"},
+		Translation: "I ca n't __",
+		Timestamp:   time.Now().Add(-time.Hour).UTC(),
+		Raw:         "I ca n't __",
+	}
+
+	got, changed := reprocessEntry(g, stored)
+	if !changed {
+		t.Fatal("reprocessEntry() didn't change entry cleaned up by old rules")
+	}
+
+	if got.Translation != "I can't" || got.ErrorCode != entry.ErrorNone || got.PostProcess != g.PostProcessVersion() {
+		t.Errorf("reprocessEntry() = %+v", got)
+	}
+
+	if !got.Timestamp.Equal(stored.Timestamp) {
+		t.Errorf("reprocessEntry() changed timestamp to %s", got.Timestamp)
+	}
+
+	if _, changed := reprocessEntry(g, got); changed {
+		t.Error("reprocessEntry() changed entry that is already up to date")
+	}
+
+	stored.Raw = "Powered by Discuz!"
+
+	if got, _ := reprocessEntry(g, stored); got.ErrorCode != entry.ErrorBadTranslation || got.Translation != "" {
+		t.Errorf("reprocessEntry() = %+v, want bad translation", got)
+	}
+}
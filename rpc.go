@@ -33,13 +33,15 @@ func (t *Comfy) Translate(req *translator.Request, reply *translator.Response) e
 	return nil
 }
 
-func ServeComfyRPC(listenAddr string) {
+func ServeComfyRPC(host, port string) {
 	comfy := new(Comfy)
 
 	rpc.Register(comfy)
 	rpc.HandleHTTP()
 
-	l, err := net.Listen("tcp", listenAddr)
+	registerAdminHandlers(http.DefaultServeMux, host)
+
+	l, err := net.Listen("tcp", net.JoinHostPort(host, port))
 	if err != nil {
 		log.Fatal("listen error:", err)
 	}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+// Sessions keeps last few translated lines of every conversation so context aware engines
+// know what was said before, least recently active sessions are forgotten first
+type Sessions struct {
+	lines int
+	idle  time.Duration
+	items *lru.Cache
+
+	now  func() time.Time
+	lock *sync.Mutex
+}
+
+type session struct {
+	lines    []translator.Line
+	lastUsed time.Time
+}
+
+func NewSessions(size, lines int, idle time.Duration) *Sessions {
+	items, _ := lru.New(size)
+
+	return &Sessions{
+		lines: lines,
+		idle:  idle,
+		items: items,
+		now:   time.Now,
+		lock:  &sync.Mutex{},
+	}
+}
+
+// Lines returns previous lines of session oldest first, nothing when session is unknown or was idle for too long
+func (s *Sessions) Lines(id string) []translator.Line {
+	if id == "" || s.lines <= 0 {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	item, ok := s.items.Get(id)
+	if !ok {
+		return nil
+	}
+
+	sess := item.(*session)
+	if s.idle > 0 && s.now().Sub(sess.lastUsed) > s.idle {
+		s.items.Remove(id)
+		return nil
+	}
+
+	out := make([]translator.Line, len(sess.lines))
+	copy(out, sess.lines)
+
+	return out
+}
+
+// Add appends translated line to session, oldest lines are dropped once there are too many
+func (s *Sessions) Add(id string, line translator.Line) {
+	if id == "" || s.lines <= 0 {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+
+	sess := &session{}
+	if item, ok := s.items.Get(id); ok {
+		sess = item.(*session)
+	}
+
+	if s.idle > 0 && now.Sub(sess.lastUsed) > s.idle {
+		sess.lines = nil
+	}
+
+	// Same line sent again, like when game redraws text box
+	if n := len(sess.lines); n > 0 && sess.lines[n-1].Text == line.Text {
+		sess.lines[n-1] = line
+	} else {
+		sess.lines = append(sess.lines, line)
+	}
+
+	if len(sess.lines) > s.lines {
+		sess.lines = append([]translator.Line(nil), sess.lines[len(sess.lines)-s.lines:]...)
+	}
+
+	sess.lastUsed = now
+
+	s.items.Add(id, sess)
+}
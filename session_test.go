@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+func TestSessions(t *testing.T) {
+	s := NewSessions(10, 2, time.Minute)
+
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	if got := s.Lines("a"); got != nil {
+		t.Errorf("Lines() of unknown session = %v", got)
+	}
+
+	s.Add("a", translator.Line{Text: "一", Translation: "one"})
+	s.Add("a", translator.Line{Text: "二", Translation: "two"})
+	s.Add("a", translator.Line{Text: "二", Translation: "two"})
+	s.Add("a", translator.Line{Text: "三", Translation: "three"})
+	s.Add("b", translator.Line{Text: "四", Translation: "four"})
+
+	// Only last 2 are kept and repeated line is stored once
+	want := []translator.Line{{Text: "二", Translation: "two"}, {Text: "三", Translation: "three"}}
+	if got := s.Lines("a"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %v, want %v", got, want)
+	}
+
+	if got := s.Lines("b"); len(got) != 1 {
+		t.Errorf("Lines() of another session = %v", got)
+	}
+
+	// Requests without session don't have any history
+	s.Add("", translator.Line{Text: "五", Translation: "five"})
+	if got := s.Lines(""); got != nil {
+		t.Errorf("Lines() without session = %v", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if got := s.Lines("a"); got != nil {
+		t.Errorf("Lines() of idle session = %v", got)
+	}
+
+	s.Add("b", translator.Line{Text: "六", Translation: "six"})
+	if got := s.Lines("b"); len(got) != 1 || got[0].Text != "六" {
+		t.Errorf("idle session wasn't started over: %v", got)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+// componentStatus tracks the last failure of a single part of the server
+type componentStatus struct {
+	OK       bool      `json:"ok"`
+	Error    string    `json:"error,omitempty"`
+	Since    time.Time `json:"since"`
+	Failures int       `json:"failures"`
+
+	// Engine is skipped until this time, zero time with Disabled set means until restart
+	Disabled      bool       `json:"disabled,omitempty"`
+	DisabledUntil *time.Time `json:"disabledUntil,omitempty"`
+}
+
+// Health keeps track of failing parts so the server can keep going in degraded mode
+type Health struct {
+	lock *sync.Mutex
+
+	cache   componentStatus
+	engines map[string]*componentStatus
+}
+
+type statusResponse struct {
+	Degraded bool                       `json:"degraded"`
+	Cache    componentStatus            `json:"cache"`
+	Engines  map[string]componentStatus `json:"engines"`
+}
+
+func NewHealth() *Health {
+	return &Health{
+		lock:    &sync.Mutex{},
+		cache:   componentStatus{OK: true, Since: time.Now()},
+		engines: make(map[string]*componentStatus),
+	}
+}
+
+// CacheResult records outcome of a cache backend operation, nil means it worked
+func (h *Health) CacheResult(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if update(&h.cache, err) {
+		if err != nil {
+			log.Errorf("Translation cache degraded, serving from memory: %v", err)
+		} else {
+			log.Info("Translation cache recovered")
+		}
+	}
+}
+
+// EngineResult records outcome of a translation request, nil means it worked
+func (h *Health) EngineResult(name string, err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if !engineFailure(err) {
+		err = nil
+	}
+
+	update(h.engine(name), err)
+}
+
+// engineFailure reports whether err means engine itself isn't working, service still answered if line was just unsupported or translated badly
+func engineFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return !errors.As(err, &translator.BadTranslationError{}) && !errors.As(err, &translator.UnsupportedError{})
+}
+
+// Disable stops engine from being used for given time, negative duration disables it until restart
+func (h *Health) Disable(name string, d time.Duration) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	s := h.engine(name)
+	s.Disabled = true
+	s.DisabledUntil = nil
+
+	if d >= 0 {
+		until := time.Now().Add(d)
+		s.DisabledUntil = &until
+	}
+}
+
+// Available reports whether engine isn't disabled
+func (h *Health) Available(name string) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	s := h.engine(name)
+	if !s.Disabled {
+		return true
+	}
+
+	if s.DisabledUntil != nil && time.Now().After(*s.DisabledUntil) {
+		s.Disabled = false
+		s.DisabledUntil = nil
+
+		return true
+	}
+
+	return false
+}
+
+func (h *Health) engine(name string) *componentStatus {
+	s, ok := h.engines[name]
+	if !ok {
+		s = &componentStatus{OK: true, Since: time.Now()}
+		h.engines[name] = s
+	}
+
+	return s
+}
+
+// update changes status and returns true if it went from working to failing or back
+func update(s *componentStatus, err error) bool {
+	if err == nil {
+		if s.OK {
+			return false
+		}
+
+		s.OK = true
+		s.Error = ""
+		s.Since = time.Now()
+		s.Failures = 0
+
+		return true
+	}
+
+	s.Error = err.Error()
+	s.Failures++
+
+	if !s.OK {
+		return false
+	}
+
+	s.OK = false
+	s.Since = time.Now()
+
+	return true
+}
+
+func (h *Health) Status() statusResponse {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	resp := statusResponse{
+		Cache:   h.cache,
+		Engines: make(map[string]componentStatus),
+	}
+
+	resp.Degraded = !h.cache.OK
+
+	for name, s := range h.engines {
+		resp.Engines[name] = *s
+
+		if !s.OK {
+			resp.Degraded = true
+		}
+	}
+
+	return resp
+}
+
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, health.Status())
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+func TestHealth_EngineResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		degraded bool
+	}{
+		{"ok", nil, false},
+		{"bad translation", translator.BadTranslationError{Input: "あ", Output: "a"}, false},
+		{"unsupported", translator.UnsupportedError{From: "ja", To: "xx"}, false},
+		{"transient", translator.TransientError{Err: errors.New("timeout")}, true},
+		{"auth", translator.AuthFailedError{Message: "bad key"}, true},
+		{"rate limited", translator.RateLimitedError{Message: "slow down"}, true},
+		{"blocked", translator.BlockedError{Message: "quota"}, true},
+		{"backend", errors.New("invalid response"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealth()
+			h.EngineResult("Google", tt.err)
+
+			if got := h.Status().Degraded; got != tt.degraded {
+				t.Errorf("Degraded = %v, want %v", got, tt.degraded)
+			}
+		})
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
+	"gitgud.io/softashell/comfy-translator/cache"
 	"gitgud.io/softashell/comfy-translator/translator"
 )
 
@@ -14,14 +16,40 @@ func translate(req translator.Request) string {
 		return req.Text
 	}
 
+	// Taken before this line is added so it only has what was said before it
+	context := sessions.Lines(req.Session)
+
+	out := translateLine(req, context)
+
+	if len(out) > 0 {
+		sessions.Add(req.Session, translator.Line{Text: req.Text, Translation: out})
+	}
+
+	return out
+}
+
+// translateLine returns translation from memo, cache or first engine that manages it
+func translateLine(req translator.Request, context []translator.Line) string {
 	start := time.Now()
 
 	var err error
-	var found bool
 	var out, source string
 
+	// Translations are shared between sessions just like cache is
+	key := req
+	key.Session = ""
+
+	if out, source, ok := memo.Get(key); ok {
+		log.WithFields(log.Fields{
+			"time":   time.Since(start),
+			"source": source + "(memo)",
+		}).Infof("%q -> %q", req.Text, out)
+
+		return out
+	}
+
 	// Checks if there are pending translation jobs for current request and wait for them to be completed
-	if ch, wait := q.Join(req); wait {
+	if ch, wait := q.Join(key); wait {
 		out := <-ch
 
 		log.WithFields(log.Fields{
@@ -32,34 +60,72 @@ func translate(req translator.Request) string {
 		return out
 	}
 
+	// Everything engines have cached for this line in one go, highest priority usable result wins
+	cached, err := c.GetAll(req)
+	if err != nil {
+		// Database is having problems, use whatever came from memory and keep going
+		health.CacheResult(err)
+	}
+
 	for _, t := range translators {
 		source = t.Name()
 
 		log.Debugf("Translating with %s", source)
 
-		out, found, err = c.Get(source, req.Text)
-		if found {
+		if result, found := cached[source]; found {
+			result = reprocess(t, req, result)
+
 			source = source + "(cache)"
 
 			// cached error
-			if err != nil {
-				log.Warnf("%s: %s", source, err)
+			if result.Err != nil {
+				log.Warnf("%s: %s", source, result.Err)
 				continue
 			}
 
 			// found translation with no errors
+			out, err = result.Translation, nil
+			c.Served(t.Name(), req)
 			break
 		}
 
-		if !t.Enabled() {
+		if !t.Enabled() || !health.Available(source) {
 			continue
 		}
 
-		out, err = t.Translate(&req)
+		if pc, ok := t.(translator.PairChecker); ok && !pc.Supports(req.From, req.To) {
+			continue
+		}
+
+		used := engineContext(t, context)
+
+		raw, err := engineTranslate(t, &req, used)
+		if errors.As(err, &translator.TransientError{}) {
+			log.Warnf("%s: %s, retrying", source, err)
+
+			raw, err = engineTranslate(t, &req, used)
+		}
+
+		result := postProcess(t, req, raw, err)
+		result.Context = used
+		out, err = result.Translation, result.Err
+
+		health.EngineResult(source, err)
 		if err != nil {
 			log.Warnf("%s: %s", source, err)
 
-			if err := c.Put(source, req.Text, out, err); err != nil {
+			if d, disable := engineBackoff(err); disable {
+				if d < 0 {
+					log.Errorf("%s: Disabled until restart", source)
+				} else {
+					log.Warnf("%s: Disabled for %s", source, d)
+				}
+
+				health.Disable(source, d)
+			}
+
+			if err := c.PutResult(source, req, result); err != nil {
+				health.CacheResult(err)
 				log.Warnf("%s: %s", source, err)
 			}
 
@@ -67,7 +133,8 @@ func translate(req translator.Request) string {
 		}
 
 		if len(out) > 0 {
-			err = c.Put(source, req.Text, out, nil)
+			err = c.PutResult(source, req, result)
+			health.CacheResult(err)
 			if err != nil {
 				log.WithFields(log.Fields{
 					"err": err,
@@ -81,9 +148,11 @@ func translate(req translator.Request) string {
 	out = matchWhitespace(out, req.Text)
 
 	// Notify waiting requests that we did the job
-	q.Push(req, out)
+	q.Push(key, out)
 
-	if len(out) < 1 {
+	if len(out) > 0 {
+		memo.Add(key, out, source)
+	} else {
 		// TODO: Return original text or try to handle error in handler
 		log.Errorf("All services failed to translate %q", req.Text)
 	}
@@ -96,3 +165,86 @@ func translate(req translator.Request) string {
 	return out
 
 }
+
+// engineContext returns previous lines engine wants to see, nothing for engines that don't use them
+func engineContext(t translator.Translator, context []translator.Line) []translator.Line {
+	ct, ok := t.(translator.ContextTranslator)
+	if !ok || ct.ContextSize() <= 0 || len(context) == 0 {
+		return nil
+	}
+
+	if n := ct.ContextSize(); len(context) > n {
+		context = context[len(context)-n:]
+	}
+
+	return context
+}
+
+func engineTranslate(t translator.Translator, req *translator.Request, context []translator.Line) (string, error) {
+	if len(context) > 0 {
+		return t.(translator.ContextTranslator).TranslateContext(req, context)
+	}
+
+	return t.Translate(req)
+}
+
+// postProcess cleans up engine output for translators that need it, raw output is kept so it can be cleaned up again later
+func postProcess(t translator.Translator, req translator.Request, raw string, err error) cache.Cached {
+	p, ok := t.(translator.PostProcessor)
+	if !ok || err != nil {
+		return cache.Cached{Translation: raw, Err: err}
+	}
+
+	out, err := p.PostProcess(&req, raw)
+
+	return cache.Cached{
+		Translation: out,
+		Err:         err,
+		Raw:         raw,
+		PostProcess: p.PostProcessVersion(),
+	}
+}
+
+// reprocess cleans up cached raw output again if it was cleaned up by older rules and stores the result
+func reprocess(t translator.Translator, req translator.Request, result cache.Cached) cache.Cached {
+	p, ok := t.(translator.PostProcessor)
+	if !ok || result.Raw == "" || result.PostProcess == p.PostProcessVersion() {
+		return result
+	}
+
+	updated := postProcess(t, req, result.Raw, nil)
+	updated.Context = result.Context
+
+	if err := c.PutResult(t.Name(), req, updated); err != nil {
+		health.CacheResult(err)
+		log.Warnf("%s: %s", t.Name(), err)
+	}
+
+	return updated
+}
+
+const (
+	rateLimitBackoff = time.Minute
+	blockedBackoff   = time.Hour
+)
+
+// engineBackoff decides if engine should be skipped for a while after error, negative duration means until restart
+func engineBackoff(err error) (time.Duration, bool) {
+	var rateLimited translator.RateLimitedError
+
+	switch {
+	case errors.As(err, &rateLimited):
+		if rateLimited.RetryAfter > 0 {
+			return rateLimited.RetryAfter, true
+		}
+
+		return rateLimitBackoff, true
+	case errors.As(err, &translator.BlockedError{}):
+		return blockedBackoff, true
+	case errors.As(err, &translator.AuthFailedError{}):
+		return -1, true
+	}
+
+	// Unsupported languages, bad translations and everything else just fall back to next engine
+	return 0, false
+}
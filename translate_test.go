@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/cache"
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+// fakeTranslator fails with errs in order and translates afterwards
+type fakeTranslator struct {
+	name  string
+	errs  []error
+	out   string
+	calls int
+}
+
+func (f *fakeTranslator) Name() string                          { return f.name }
+func (f *fakeTranslator) Start(c config.TranslatorConfig) error { return nil }
+func (f *fakeTranslator) Enabled() bool                         { return true }
+
+func (f *fakeTranslator) Translate(req *translator.Request) (string, error) {
+	f.calls++
+
+	if f.calls <= len(f.errs) {
+		return "", f.errs[f.calls-1]
+	}
+
+	return f.out, nil
+}
+
+// useTranslators points globals used by translateLine at a fresh cache and given engines
+func useTranslators(t *testing.T, ts ...translator.Translator) {
+	oldC, oldQ, oldMemo, oldHealth, oldTranslators := c, q, memo, health, translators
+
+	var names []string
+	for _, tr := range ts {
+		names = append(names, tr.Name())
+	}
+
+	var err error
+
+	c, err = cache.Open("sqlite:"+filepath.Join(t.TempDir(), "translation.db"), config.NewConfig(), names)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q = NewQueue()
+	memo = NewAnswerMemo(answerMemoSize, answerMemoTTL)
+	health = NewHealth()
+	translators = ts
+
+	t.Cleanup(func() {
+		c.Close()
+		c, q, memo, health, translators = oldC, oldQ, oldMemo, oldHealth, oldTranslators
+	})
+}
+
+func TestTranslateLine_EngineErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		want      string
+		wantCalls int
+		disabled  bool
+		// Expected backoff when disabled, negative means until restart
+		backoff time.Duration
+	}{
+		{
+			name:      "retry once on transient",
+			errs:      []error{translator.TransientError{Err: errors.New("connection reset")}},
+			want:      "engine",
+			wantCalls: 2,
+		},
+		{
+			name: "fall back after second transient",
+			errs: []error{
+				translator.TransientError{Err: errors.New("connection reset")},
+				translator.TransientError{Err: errors.New("connection reset")},
+			},
+			want:      "fallback",
+			wantCalls: 2,
+		},
+		{
+			name:      "disable on auth",
+			errs:      []error{translator.AuthFailedError{Message: "401 Unauthorized"}},
+			want:      "fallback",
+			wantCalls: 1,
+			disabled:  true,
+			backoff:   -1,
+		},
+		{
+			name:      "rate limit with retry after",
+			errs:      []error{translator.RateLimitedError{RetryAfter: 30 * time.Second}},
+			want:      "fallback",
+			wantCalls: 1,
+			disabled:  true,
+			backoff:   30 * time.Second,
+		},
+		{
+			name:      "rate limit without retry after",
+			errs:      []error{translator.RateLimitedError{}},
+			want:      "fallback",
+			wantCalls: 1,
+			disabled:  true,
+			backoff:   rateLimitBackoff,
+		},
+		{
+			name:      "blocked",
+			errs:      []error{translator.BlockedError{Message: "403 Forbidden"}},
+			want:      "fallback",
+			wantCalls: 1,
+			disabled:  true,
+			backoff:   blockedBackoff,
+		},
+		{
+			name:      "unsupported",
+			errs:      []error{translator.UnsupportedError{From: "ja", To: "en"}},
+			want:      "fallback",
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &fakeTranslator{name: "Engine", errs: tt.errs, out: "engine"}
+			fallback := &fakeTranslator{name: "Fallback", out: "fallback"}
+
+			useTranslators(t, engine, fallback)
+
+			start := time.Now()
+
+			if got := translateLine(translator.Request{Text: "テスト", From: "ja", To: "en"}, nil); got != tt.want {
+				t.Errorf("translateLine() = %q, want %q", got, tt.want)
+			}
+
+			if engine.calls != tt.wantCalls {
+				t.Errorf("engine called %d times, want %d", engine.calls, tt.wantCalls)
+			}
+
+			s := health.engine(engine.Name())
+			if s.Disabled != tt.disabled {
+				t.Fatalf("engine disabled = %v, want %v", s.Disabled, tt.disabled)
+			}
+
+			if !tt.disabled {
+				return
+			}
+
+			if tt.backoff < 0 {
+				if s.DisabledUntil != nil {
+					t.Errorf("engine disabled until %s, want until restart", s.DisabledUntil)
+				}
+
+				return
+			}
+
+			if s.DisabledUntil == nil {
+				t.Fatalf("engine disabled until restart, want for %s", tt.backoff)
+			}
+
+			if d := s.DisabledUntil.Sub(start); d < tt.backoff || d > tt.backoff+time.Minute {
+				t.Errorf("engine disabled for %s, want %s", d, tt.backoff)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package translator
+
+import (
+	"fmt"
+	"time"
+)
+
+// BatchFunc translates several texts between the same languages in one call, output has to match input order
+type BatchFunc func(from, to string, texts []string) ([]string, error)
+
+// Batcher groups requests arriving at about the same time so engines that accept
+// multiple texts per call can translate them together, callers still get their own result
+type Batcher struct {
+	translate BatchFunc
+
+	// How long to wait for more requests after the first one
+	window time.Duration
+	// Max texts and characters in a single call, 0 means no limit
+	maxCount  int
+	maxLength int
+
+	in chan batchItem
+}
+
+type batchItem struct {
+	req *Request
+	out chan batchResult
+}
+
+type batchResult struct {
+	text string
+	err  error
+}
+
+func NewBatcher(translate BatchFunc, window time.Duration, maxCount, maxLength int) *Batcher {
+	b := &Batcher{
+		translate: translate,
+		window:    window,
+		maxCount:  maxCount,
+		maxLength: maxLength,
+		in:        make(chan batchItem),
+	}
+
+	go b.worker()
+
+	return b
+}
+
+// Translate queues request and waits until its batch is translated
+func (b *Batcher) Translate(req *Request) (string, error) {
+	item := batchItem{req: req, out: make(chan batchResult, 1)}
+
+	b.in <- item
+
+	out := <-item.out
+
+	return out.text, out.err
+}
+
+func (b *Batcher) worker() {
+	for first := range b.in {
+		items := []batchItem{first}
+		length := len([]rune(first.req.Text))
+
+		timer := time.NewTimer(b.window)
+
+	collect:
+		for (b.maxCount <= 0 || len(items) < b.maxCount) && (b.maxLength <= 0 || length < b.maxLength) {
+			select {
+			case item := <-b.in:
+				items = append(items, item)
+				length += len([]rune(item.req.Text))
+			case <-timer.C:
+				break collect
+			}
+		}
+
+		timer.Stop()
+
+		b.run(items)
+	}
+}
+
+// run translates collected requests, one call for each language pair
+func (b *Batcher) run(items []batchItem) {
+	type pair struct{ from, to string }
+
+	var order []pair
+	groups := make(map[pair][]batchItem)
+
+	for _, item := range items {
+		p := pair{item.req.From, item.req.To}
+		if _, ok := groups[p]; !ok {
+			order = append(order, p)
+		}
+
+		groups[p] = append(groups[p], item)
+	}
+
+	for _, p := range order {
+		group := groups[p]
+
+		texts := make([]string, len(group))
+		for i, item := range group {
+			texts[i] = item.req.Text
+		}
+
+		out, err := b.translate(p.from, p.to, texts)
+		if err == nil && len(out) != len(texts) {
+			err = fmt.Errorf("got %d translations for %d texts", len(out), len(texts))
+		}
+
+		for i, item := range group {
+			if err != nil {
+				item.out <- batchResult{err: err}
+				continue
+			}
+
+			item.out <- batchResult{text: out[i]}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package translator
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatcher(t *testing.T) {
+	var lock sync.Mutex
+	var calls [][]string
+
+	b := NewBatcher(func(from, to string, texts []string) ([]string, error) {
+		lock.Lock()
+		calls = append(calls, texts)
+		lock.Unlock()
+
+		if to == "xx" {
+			return nil, errors.New("unsupported")
+		}
+
+		out := make([]string, len(texts))
+		for i := range texts {
+			out[i] = strings.ToUpper(texts[i]) + " " + to
+		}
+
+		return out, nil
+	}, 50*time.Millisecond, 3, 0)
+
+	reqs := []Request{
+		{Text: "a", From: "ja", To: "en"},
+		{Text: "b", From: "ja", To: "en"},
+		{Text: "c", From: "ja", To: "ru"},
+		{Text: "d", From: "ja", To: "xx"},
+	}
+
+	type result struct {
+		text string
+		err  error
+	}
+
+	results := make([]result, len(reqs))
+
+	var wg sync.WaitGroup
+	for i := range reqs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i].text, results[i].err = b.Translate(&reqs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, want := range []string{"A en", "B en", "C ru"} {
+		if results[i].err != nil || results[i].text != want {
+			t.Errorf("Translate(%q) = %q, %v, want %q", reqs[i].Text, results[i].text, results[i].err, want)
+		}
+	}
+
+	if results[3].err == nil {
+		t.Errorf("Translate(%q) expected error", reqs[3].Text)
+	}
+
+	// 4 requests with max 3 per batch end up in at least 2 batches, every call has a single language pair
+	var texts int
+	for _, c := range calls {
+		if len(c) > 3 {
+			t.Errorf("batch %v is over the limit", c)
+		}
+
+		texts += len(c)
+	}
+
+	if texts != len(reqs) {
+		t.Errorf("translated %d texts, want %d", texts, len(reqs))
+	}
+}
+
+func TestBatcher_CountMismatch(t *testing.T) {
+	b := NewBatcher(func(from, to string, texts []string) ([]string, error) {
+		return nil, nil
+	}, time.Millisecond, 0, 0)
+
+	if _, err := b.Translate(&Request{Text: "a", From: "ja", To: "en"}); err == nil {
+		t.Error("expected error when engine returns fewer translations")
+	}
+}
@@ -0,0 +1,277 @@
+package bing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+const (
+	defaultAPIURL = "https://api.cognitive.microsofttranslator.com"
+	apiTimeout    = 10 * time.Second
+
+	// https://learn.microsoft.com/azure/ai-services/translator/reference/v3-0-translate#request-limits
+	maxTexts  = 100
+	maxLength = 10000
+
+	batchWindow = 100 * time.Millisecond
+)
+
+// Result is a translation from Microsoft Translator api along with details only it provides
+type Result struct {
+	Text      string
+	Alignment []Alignment
+}
+
+// Alignment maps a range of characters in source text to a range in translation, ends are inclusive
+type Alignment struct {
+	SourceStart int
+	SourceEnd   int
+	TargetStart int
+	TargetEnd   int
+}
+
+// Alternative is one of possible translations of a word or short phrase found in dictionary
+type Alternative struct {
+	Text             string
+	PosTag           string
+	Confidence       float64
+	BackTranslations []string
+}
+
+type apiText struct {
+	Text string `json:"Text"`
+}
+
+type apiTranslateResponse []struct {
+	Translations []struct {
+		Text      string `json:"text"`
+		To        string `json:"to"`
+		Alignment struct {
+			Proj string `json:"proj"`
+		} `json:"alignment"`
+	} `json:"translations"`
+}
+
+type apiLookupResponse []struct {
+	Translations []struct {
+		DisplayTarget    string  `json:"displayTarget"`
+		PosTag           string  `json:"posTag"`
+		Confidence       float64 `json:"confidence"`
+		BackTranslations []struct {
+			DisplayText string `json:"displayText"`
+		} `json:"backTranslations"`
+	} `json:"translations"`
+}
+
+type apiError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// apiStatusError maps api errors https://learn.microsoft.com/azure/ai-services/translator/reference/v3-0-reference#errors
+func apiStatusError(from, to string) translator.StatusErrorFunc {
+	return func(resp *http.Response, body []byte) error {
+		var e apiError
+		if err := json.Unmarshal(body, &e); err != nil {
+			return translator.StatusError(resp, body)
+		}
+
+		msg := fmt.Sprintf("%s - %d %s", resp.Status, e.Error.Code, e.Error.Message)
+
+		switch e.Error.Code {
+		case 400023, 400035, 400036:
+			return translator.UnsupportedError{From: from, To: to, Message: msg}
+		case 403001:
+			return translator.BlockedError{Message: "free quota exceeded: " + msg}
+		}
+
+		return translator.StatusError(resp, body)
+	}
+}
+
+func (t *Translate) startAPI(c config.TranslatorConfig) error {
+	t.apiURL = strings.TrimSuffix(c.URL, "/")
+	if len(t.apiURL) < 1 {
+		t.apiURL = defaultAPIURL
+	}
+
+	t.apiKey = c.Key
+	t.region = c.Region
+
+	t.batcher = translator.NewBatcher(t.translateBatch, batchWindow, maxTexts, maxLength)
+
+	t.api = true
+	t.enabled = true
+
+	return nil
+}
+
+func (t *Translate) translateBatch(from, to string, texts []string) ([]string, error) {
+	results, err := t.translateArray(from, to, texts, false)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]string, len(results))
+	for i := range results {
+		out[i] = results[i].Text
+	}
+
+	return out, nil
+}
+
+// TranslateArray translates texts in one request along with alignment of words in them, needs a key
+func (t *Translate) TranslateArray(from, to string, texts []string) ([]Result, error) {
+	return t.translateArray(from, to, texts, true)
+}
+
+// translateArray only asks for alignment when it's wanted since it makes api slower
+func (t *Translate) translateArray(from, to string, texts []string, alignment bool) ([]Result, error) {
+	params := url.Values{}
+	params.Set("from", from)
+	params.Set("to", to)
+	params.Set("textType", "plain")
+	if alignment {
+		params.Set("includeAlignment", "true")
+	}
+
+	var response apiTranslateResponse
+	if err := t.call("/translate", params, texts, from, to, &response); err != nil {
+		return nil, err
+	}
+
+	if len(response) != len(texts) {
+		return nil, fmt.Errorf("got %d translations for %d texts", len(response), len(texts))
+	}
+
+	results := make([]Result, len(response))
+
+	for i := range response {
+		if len(response[i].Translations) < 1 {
+			return nil, fmt.Errorf("Empty translation for %q", texts[i])
+		}
+
+		tr := response[i].Translations[0]
+
+		results[i] = Result{
+			Text:      tr.Text,
+			Alignment: parseAlignment(tr.Alignment.Proj),
+		}
+	}
+
+	return results, nil
+}
+
+// Lookup returns alternative translations of a word or short phrase from dictionary, needs a key
+func (t *Translate) Lookup(from, to, text string) ([]Alternative, error) {
+	params := url.Values{}
+	params.Set("from", from)
+	params.Set("to", to)
+
+	var response apiLookupResponse
+	if err := t.call("/dictionary/lookup", params, []string{text}, from, to, &response); err != nil {
+		return nil, err
+	}
+
+	var alternatives []Alternative
+
+	for _, entry := range response {
+		for _, tr := range entry.Translations {
+			alt := Alternative{
+				Text:       tr.DisplayTarget,
+				PosTag:     tr.PosTag,
+				Confidence: tr.Confidence,
+			}
+
+			for _, back := range tr.BackTranslations {
+				alt.BackTranslations = append(alt.BackTranslations, back.DisplayText)
+			}
+
+			alternatives = append(alternatives, alt)
+		}
+	}
+
+	return alternatives, nil
+}
+
+// call sends texts to api endpoint and decodes response into out
+func (t *Translate) call(path string, params url.Values, texts []string, from, to string, out interface{}) error {
+	if !t.api {
+		return fmt.Errorf("Bing: Microsoft Translator api needs a key")
+	}
+
+	params.Set("api-version", "3.0")
+
+	body := make([]apiText, len(texts))
+	for i := range texts {
+		body[i].Text = texts[i]
+	}
+
+	header := http.Header{"Ocp-Apim-Subscription-Key": {t.apiKey}}
+	// Only needed for regional and multi service resources
+	if len(t.region) > 0 {
+		header.Set("Ocp-Apim-Subscription-Region", t.region)
+	}
+
+	return translator.PostJSON(t.apiClient, t.apiURL+path+"?"+params.Encode(), header, body, out, apiStatusError(from, to))
+}
+
+// parseAlignment reads alignment like "0:2-0:4 3:5-6:9", pairs that can't be read are skipped
+func parseAlignment(proj string) []Alignment {
+	var out []Alignment
+
+	for _, field := range strings.Fields(proj) {
+		parts := strings.Split(field, "-")
+		if len(parts) != 2 {
+			continue
+		}
+
+		source, ok := parseRange(parts[0])
+		if !ok {
+			continue
+		}
+
+		target, ok := parseRange(parts[1])
+		if !ok {
+			continue
+		}
+
+		out = append(out, Alignment{
+			SourceStart: source[0],
+			SourceEnd:   source[1],
+			TargetStart: target[0],
+			TargetEnd:   target[1],
+		})
+	}
+
+	return out
+}
+
+func parseRange(s string) ([2]int, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return [2]int{}, false
+	}
+
+	start, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return [2]int{}, false
+	}
+
+	end, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return [2]int{}, false
+	}
+
+	return [2]int{start, end}, true
+}
@@ -0,0 +1,211 @@
+package bing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+	"gitgud.io/softashell/comfy-translator/translator/translatortest"
+)
+
+func newTestTranslate(t *testing.T, h http.HandlerFunc) *Translate {
+	tr := New()
+	translatortest.Start(t, tr, config.TranslatorConfig{Key: "secret", Region: "westeurope", URL: "/"}, h)
+
+	return tr
+}
+
+func TestTranslate_API(t *testing.T) {
+	var lock sync.Mutex
+	var batches int
+
+	tr := newTestTranslate(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/translate" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+
+		q := r.URL.Query()
+		if q.Get("api-version") != "3.0" || q.Get("from") != "ja" || q.Get("to") != "en" {
+			t.Errorf("query = %v", q)
+		}
+
+		if r.Header.Get("Ocp-Apim-Subscription-Key") != "secret" || r.Header.Get("Ocp-Apim-Subscription-Region") != "westeurope" {
+			t.Errorf("headers = %v", r.Header)
+		}
+
+		var body []apiText
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+
+		// Only detailed translations ask for alignment
+		if q.Get("includeAlignment") == "true" {
+			fmt.Fprintf(w, `[{"translations": [{"text": "en %s", "to": "en", "alignment": {"proj": "0:0-0:1"}}]}]`, body[0].Text)
+			return
+		}
+
+		lock.Lock()
+		batches++
+		lock.Unlock()
+
+		var out []string
+		for _, text := range body {
+			out = append(out, fmt.Sprintf(`{"translations": [{"text": "en %s", "to": "en"}]}`, text.Text))
+		}
+
+		fmt.Fprintf(w, "[%s]", strings.Join(out, ","))
+	})
+
+	translatortest.Batched(t, tr, []string{"一", "二", "三"}, func(text string) string { return "en " + text }, func() int {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return batches
+	})
+
+	detailed, err := tr.TranslateArray("ja", "en", []string{"一"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Result{{Text: "en 一", Alignment: []Alignment{{SourceStart: 0, SourceEnd: 0, TargetStart: 0, TargetEnd: 1}}}}
+	if !reflect.DeepEqual(detailed, want) {
+		t.Errorf("TranslateArray() = %+v, want %+v", detailed, want)
+	}
+}
+
+func TestTranslate_Lookup(t *testing.T) {
+	tr := newTestTranslate(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dictionary/lookup" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+
+		fmt.Fprint(w, `[{"normalizedSource": "先輩", "translations": [
+			{"displayTarget": "senior", "posTag": "NOUN", "confidence": 0.7, "backTranslations": [{"displayText": "先輩"}, {"displayText": "上級"}]},
+			{"displayTarget": "elder", "posTag": "NOUN", "confidence": 0.3, "backTranslations": []}
+		]}]`)
+	})
+
+	got, err := tr.Lookup("ja", "en", "先輩")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Alternative{
+		{Text: "senior", PosTag: "NOUN", Confidence: 0.7, BackTranslations: []string{"先輩", "上級"}},
+		{Text: "elder", PosTag: "NOUN", Confidence: 0.3},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lookup() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTranslate_APIErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   interface{}
+	}{
+		{"quota", http.StatusForbidden, `{"error": {"code": 403001, "message": "The operation is not allowed because the subscription has exceeded its free quota."}}`, &translator.BlockedError{}},
+		{"bad key", http.StatusUnauthorized, `{"error": {"code": 401000, "message": "The request is not authorized because credentials are missing or invalid."}}`, &translator.AuthFailedError{}},
+		{"rate limited", http.StatusTooManyRequests, `{"error": {"code": 429001, "message": "The server rejected the request because the client has exceeded request limits."}}`, &translator.RateLimitedError{}},
+		{"bad language", http.StatusBadRequest, `{"error": {"code": 400036, "message": "The target language is not valid."}}`, &translator.UnsupportedError{}},
+		{"server error", http.StatusServiceUnavailable, `{"error": {"code": 503000, "message": "Service is temporarily unavailable."}}`, &translator.TransientError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestTranslate(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+
+			_, err := tr.batcher.Translate(&translator.Request{Text: "おはよう", From: "ja", To: "xx"})
+			if !errors.As(err, tt.want) {
+				t.Errorf("Translate() error = %#v, want %T", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslate_APIFallback(t *testing.T) {
+	var scraped []string
+
+	scraper := translatortest.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scraped = append(scraped, r.URL.Path)
+
+		if r.URL.Path == "/translator/api/Translate/TranslateArray" {
+			fmt.Fprint(w, `{"from": "ja", "to": "en", "items": [{"text": "Good morning", "wordAlignment": ""}]}`)
+		}
+	}))
+
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		want     string
+		wantErr  interface{}
+		scraping bool
+	}{
+		{"server error", http.StatusServiceUnavailable, `{"error": {"code": 503000, "message": "Service is temporarily unavailable."}}`, "Good morning", nil, true},
+		{"quota", http.StatusForbidden, `{"error": {"code": 403001, "message": "Free quota exceeded."}}`, "", &translator.BlockedError{}, false},
+		{"bad key", http.StatusUnauthorized, `{"error": {"code": 401000, "message": "The request is not authorized because credentials are missing or invalid."}}`, "", &translator.AuthFailedError{}, false},
+		{"rate limited", http.StatusTooManyRequests, `{"error": {"code": 429001, "message": "The server rejected the request because the client has exceeded request limits."}}`, "", &translator.RateLimitedError{}, false},
+		{"bad language", http.StatusBadRequest, `{"error": {"code": 400036, "message": "The target language is not valid."}}`, "", &translator.UnsupportedError{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scraped = nil
+
+			tr := newTestTranslate(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+
+			tr.pageURL = scraper.URL + "/translator"
+			tr.scrapeURL = scraper.URL + "/translator/api/Translate/TranslateArray"
+			tr.scrapeDelay = 0
+
+			out, err := tr.Translate(&translator.Request{Text: "おはよう", From: "ja", To: "en"})
+			if tt.wantErr != nil && !errors.As(err, tt.wantErr) {
+				t.Errorf("Translate() error = %#v, want %T", err, tt.wantErr)
+			} else if tt.wantErr == nil && err != nil {
+				t.Errorf("Translate() error = %v", err)
+			}
+
+			if out != tt.want {
+				t.Errorf("Translate() = %q, want %q", out, tt.want)
+			}
+
+			if got := len(scraped) > 0; got != tt.scraping {
+				t.Errorf("scraped = %v, want %v", scraped, tt.scraping)
+			}
+		})
+	}
+}
+
+func TestParseAlignment(t *testing.T) {
+	got := parseAlignment("0:2-0:4 3:5-6:9 broken 7-8")
+	want := []Alignment{
+		{SourceStart: 0, SourceEnd: 2, TargetStart: 0, TargetEnd: 4},
+		{SourceStart: 3, SourceEnd: 5, TargetStart: 6, TargetEnd: 9},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAlignment() = %+v, want %+v", got, want)
+	}
+
+	if got := parseAlignment(""); got != nil {
+		t.Errorf("parseAlignment(\"\") = %+v, want nil", got)
+	}
+}
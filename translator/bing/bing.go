@@ -3,6 +3,7 @@ package bing
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -37,6 +38,19 @@ type Translate struct {
 	requests int
 
 	cookieExpiration time.Time
+
+	// Scraped pages and time between requests to them
+	pageURL     string
+	scrapeURL   string
+	scrapeDelay time.Duration
+
+	// Official api is used instead of scraping when key is set
+	api       bool
+	apiClient *http.Client
+	apiURL    string
+	apiKey    string
+	region    string
+	batcher   *translator.Batcher
 }
 
 type translateArrayRequest struct {
@@ -71,6 +85,12 @@ func New() *Translate {
 		mutex:       &sync.Mutex{},
 
 		cookieExpiration: time.Now(),
+
+		pageURL:     translatorURL,
+		scrapeURL:   translatorAPI,
+		scrapeDelay: delay,
+
+		apiClient: &http.Client{Timeout: apiTimeout},
 	}
 }
 
@@ -79,6 +99,12 @@ func (t *Translate) Name() string {
 }
 
 func (t *Translate) Start(c config.TranslatorConfig) error {
+	if len(c.Key) > 0 {
+		return t.startAPI(c)
+	}
+
+	log.Info("Bing: No key set, scraping bing.com/translator instead of using Microsoft Translator api")
+
 	err := t.getCookies()
 	if err != nil {
 		return err
@@ -94,12 +120,29 @@ func (t *Translate) Enabled() bool {
 }
 
 func (t *Translate) Translate(req *translator.Request) (string, error) {
+	if !t.api {
+		return t.translateScraped(req)
+	}
+
+	out, err := t.batcher.Translate(req)
+	if !errors.As(err, &translator.TransientError{}) {
+		return out, err
+	}
+
+	// Scraping might still work while api is down, other errors are reported so engine backs off
+	log.Warnf("Bing: Microsoft Translator api failed, scraping bing.com/translator instead: %v", err)
+
+	return t.translateScraped(req)
+}
+
+// translateScraped uses the api behind bing.com/translator page, works without a key but refuses connections a lot
+func (t *Translate) translateScraped(req *translator.Request) (string, error) {
 	log.Debugf("Translating %q from %q to %q", req.Text, req.From, req.To)
 
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
-	translator.CheckThrottle(t.lastRequest, delay)
+	translator.CheckThrottle(t.lastRequest, t.scrapeDelay)
 
 	if time.Now().After(t.cookieExpiration) || t.requests > 3 {
 		err := t.getCookies()
@@ -108,10 +151,10 @@ func (t *Translate) Translate(req *translator.Request) (string, error) {
 		}
 	}
 
-	translator.CheckThrottle(t.lastRequest, delay)
+	translator.CheckThrottle(t.lastRequest, t.scrapeDelay)
 
 	var URL *url.URL
-	URL, err := url.Parse(translatorAPI)
+	URL, err := url.Parse(t.scrapeURL)
 
 	parameters := url.Values{}
 	parameters.Add("from", req.From)
@@ -147,12 +190,14 @@ func (t *Translate) Translate(req *translator.Request) (string, error) {
 	resp, err := t.client.Do(r)
 	if err != nil {
 		log.Errorln("Failed to do request", err)
-		return "", err
+		return "", translator.TransientError{Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("%s", resp.Status)
+		contents, _ := ioutil.ReadAll(resp.Body)
+
+		return "", translator.ScraperStatusError(resp, contents)
 	}
 
 	//{"from":"en","to":"ja","items":[{"text":"ハローワールド！","wordAlignment":""}]}
@@ -188,7 +233,7 @@ func (t *Translate) getCookies() error {
 	log.Print("Getting bing cookies")
 
 	var URL *url.URL
-	URL, err := url.Parse(translatorURL)
+	URL, err := url.Parse(t.pageURL)
 	if err != nil {
 		panic(err)
 	}
@@ -209,12 +254,12 @@ func (t *Translate) getCookies() error {
 
 		t.cookieExpiration = time.Now()
 
-		return err
+		return translator.TransientError{Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("%s", resp.Status)
+		return translator.ScraperStatusError(resp, nil)
 	}
 
 	t.cookieExpiration = time.Now().Add(time.Minute * 10)
@@ -0,0 +1,149 @@
+package deepl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+const (
+	freeURL = "https://api-free.deepl.com/v2/translate"
+	proURL  = "https://api.deepl.com/v2/translate"
+
+	// Keys for free api end with this
+	freeKeySuffix = ":fx"
+
+	// https://www.deepl.com/docs-api/translating-text/request
+	maxTexts  = 50
+	maxLength = 30000
+
+	batchWindow = 100 * time.Millisecond
+)
+
+type Translate struct {
+	enabled bool
+	client  *http.Client
+	batcher *translator.Batcher
+
+	apiURL    string
+	apiKey    string
+	formality string
+}
+
+type deeplResponse struct {
+	Translations []struct {
+		DetectedSourceLanguage string `json:"detected_source_language"`
+		Text                   string `json:"text"`
+	} `json:"translations"`
+}
+
+type deeplError struct {
+	Message string `json:"message"`
+}
+
+// statusError maps api errors https://www.deepl.com/docs-api/api-access/general-information
+func statusError(from, to string) translator.StatusErrorFunc {
+	return func(resp *http.Response, body []byte) error {
+		msg := resp.Status
+
+		var e deeplError
+		if err := json.Unmarshal(body, &e); err == nil && len(e.Message) > 0 {
+			msg = fmt.Sprintf("%s - %s", resp.Status, e.Message)
+		}
+
+		switch resp.StatusCode {
+		case 456:
+			return translator.BlockedError{Message: "quota exceeded: " + msg}
+		case http.StatusBadRequest:
+			if strings.Contains(e.Message, "not supported") {
+				return translator.UnsupportedError{From: from, To: to, Message: msg}
+			}
+		case 529:
+			return translator.RateLimitedError{Message: msg}
+		}
+
+		return translator.StatusError(resp, body)
+	}
+}
+
+func New() *Translate {
+	t := &Translate{
+		client: &http.Client{Timeout: (10 * time.Second)},
+	}
+
+	t.batcher = translator.NewBatcher(t.translateBatch, batchWindow, maxTexts, maxLength)
+
+	return t
+}
+
+func (t *Translate) Name() string {
+	return "DeepL"
+}
+
+func (t *Translate) Start(c config.TranslatorConfig) error {
+	t.apiKey = c.Key
+	if len(t.apiKey) < 1 {
+		return fmt.Errorf("%s: No api key provided, edit comfy-translator.toml to disable or change key", t.Name())
+	}
+
+	t.apiURL = proURL
+	if strings.HasSuffix(t.apiKey, freeKeySuffix) {
+		t.apiURL = freeURL
+	}
+
+	switch c.Formality {
+	case "", "default", "more", "less", "prefer_more", "prefer_less":
+		t.formality = c.Formality
+	default:
+		return fmt.Errorf("%s: Unknown formality %q, use default, more, less, prefer_more or prefer_less", t.Name(), c.Formality)
+	}
+
+	t.enabled = true
+
+	return nil
+}
+
+func (t *Translate) Enabled() bool {
+	return t.enabled
+}
+
+func (t *Translate) Translate(req *translator.Request) (string, error) {
+	return t.batcher.Translate(req)
+}
+
+func (t *Translate) translateBatch(from, to string, texts []string) ([]string, error) {
+	parameters := url.Values{}
+	for _, text := range texts {
+		parameters.Add("text", text)
+	}
+	parameters.Add("source_lang", strings.ToUpper(from))
+	parameters.Add("target_lang", strings.ToUpper(to))
+
+	if len(t.formality) > 0 {
+		parameters.Add("formality", t.formality)
+	}
+
+	header := http.Header{"Authorization": {"DeepL-Auth-Key " + t.apiKey}}
+
+	var response deeplResponse
+	if err := translator.PostForm(t.client, t.apiURL, header, parameters, &response, statusError(from, to)); err != nil {
+		return nil, err
+	}
+
+	if len(response.Translations) != len(texts) {
+		return nil, fmt.Errorf("got %d translations for %d texts", len(response.Translations), len(texts))
+	}
+
+	out := make([]string, len(texts))
+	for i := range response.Translations {
+		out[i] = response.Translations[i].Text
+	}
+
+	return out, nil
+}
@@ -0,0 +1,121 @@
+package deepl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+	"gitgud.io/softashell/comfy-translator/translator/translatortest"
+)
+
+func newTestTranslate(t *testing.T, key string, h http.HandlerFunc) *Translate {
+	srv := translatortest.NewServer(t, h)
+
+	tr := New()
+	if err := tr.Start(config.TranslatorConfig{Key: key, Formality: "less"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tr.apiURL = srv.URL
+
+	return tr
+}
+
+func TestTranslate_Start(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    config.TranslatorConfig
+		wantURL string
+		wantErr bool
+	}{
+		{"free key", config.TranslatorConfig{Key: "abc:fx"}, freeURL, false},
+		{"pro key", config.TranslatorConfig{Key: "abc"}, proURL, false},
+		{"no key", config.TranslatorConfig{}, "", true},
+		{"bad formality", config.TranslatorConfig{Key: "abc", Formality: "polite"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := New()
+
+			err := tr.Start(tt.conf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Start() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tr.Enabled() == tt.wantErr || (!tt.wantErr && tr.apiURL != tt.wantURL) {
+				t.Errorf("Start() url = %q, enabled = %v", tr.apiURL, tr.Enabled())
+			}
+		})
+	}
+}
+
+func TestTranslate_Translate(t *testing.T) {
+	var lock sync.Mutex
+	var requests int
+
+	tr := newTestTranslate(t, "secret:fx", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests++
+		lock.Unlock()
+
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key secret:fx" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+
+		r.ParseForm()
+
+		if r.Form.Get("source_lang") != "JA" || r.Form.Get("target_lang") != "EN" || r.Form.Get("formality") != "less" {
+			t.Errorf("unexpected parameters %v", r.Form)
+		}
+
+		var items []string
+		for _, text := range r.Form["text"] {
+			items = append(items, fmt.Sprintf(`{"detected_source_language": "JA", "text": %q}`, "translated "+text))
+		}
+
+		fmt.Fprintf(w, `{"translations": [%s]}`, strings.Join(items, ","))
+	})
+
+	translatortest.Batched(t, tr, []string{"一", "二", "三", "四"}, func(text string) string { return "translated " + text }, func() int {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return requests
+	})
+}
+
+func TestTranslate_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   interface{}
+	}{
+		{"quota exceeded", 456, `{"message": "Quota exceeded"}`, &translator.BlockedError{}},
+		{"too many requests", http.StatusTooManyRequests, `{"message": "Too many requests"}`, &translator.RateLimitedError{}},
+		{"high load", 529, `{"message": "Too many requests"}`, &translator.RateLimitedError{}},
+		{"bad key", http.StatusForbidden, `{"message": "Wrong endpoint"}`, &translator.AuthFailedError{}},
+		{"unsupported", http.StatusBadRequest, `{"message": "Value for 'target_lang' not supported."}`, &translator.UnsupportedError{}},
+		{"server error", http.StatusInternalServerError, ``, &translator.TransientError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestTranslate(t, "secret", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+
+			_, err := tr.Translate(&translator.Request{Text: "テスト", From: "ja", To: "en"})
+			if !errors.As(err, tt.want) {
+				t.Errorf("Translate() error = %#v, want %T", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package translator
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitedError means service wants us to slow down, RetryAfter is zero when it didn't say for how long
+type RateLimitedError struct {
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e RateLimitedError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limited, retry after %s: %s", e.RetryAfter, e.Message)
+	}
+
+	return fmt.Sprintf("rate limited: %s", e.Message)
+}
+
+// AuthFailedError means api key is missing, invalid or expired
+type AuthFailedError struct {
+	Message string
+}
+
+func (e AuthFailedError) Error() string {
+	return fmt.Sprintf("authentication failed: %s", e.Message)
+}
+
+// UnsupportedError means service can't translate between requested languages
+type UnsupportedError struct {
+	From    string
+	To      string
+	Message string
+}
+
+func (e UnsupportedError) Error() string {
+	return fmt.Sprintf("unsupported language pair %s-%s: %s", e.From, e.To, e.Message)
+}
+
+// TransientError is a network or server problem that will probably go away on its own
+type TransientError struct {
+	Err error
+}
+
+func (e TransientError) Error() string {
+	return fmt.Sprintf("temporary failure: %v", e.Err)
+}
+
+func (e TransientError) Unwrap() error {
+	return e.Err
+}
+
+// BlockedError means service refuses to work for us, like when quota is used up or the key got banned
+type BlockedError struct {
+	Message string
+}
+
+func (e BlockedError) Error() string {
+	return fmt.Sprintf("blocked: %s", e.Message)
+}
+
+type BadTranslationError struct {
+	Input  string
+	Output string
+}
+
+func (e BadTranslationError) Error() string {
+	return fmt.Sprintf("garbage translation: %q => %q", e.Input, e.Output)
+}
+
+// StatusError turns unsuccessful http response into matching error type
+func StatusError(resp *http.Response, body []byte) error {
+	msg := statusMessage(resp, body)
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return RateLimitedError{
+			RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After")),
+			Message:    msg,
+		}
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return AuthFailedError{Message: msg}
+	case resp.StatusCode == http.StatusUnavailableForLegalReasons:
+		return BlockedError{Message: msg}
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500:
+		return TransientError{Err: fmt.Errorf("%s", msg)}
+	}
+
+	return fmt.Errorf("%s", msg)
+}
+
+// ScraperStatusError is StatusError for requests made without api key, 401 and 403 mean service is blocking
+// us for a while then since there's no key that could be wrong
+func ScraperStatusError(resp *http.Response, body []byte) error {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return BlockedError{Message: statusMessage(resp, body)}
+	}
+
+	return StatusError(resp, body)
+}
+
+func statusMessage(resp *http.Response, body []byte) string {
+	if text := strings.TrimSpace(string(body)); len(text) > 0 {
+		return fmt.Sprintf("%s - %s", resp.Status, text)
+	}
+
+	return resp.Status
+}
+
+// ParseRetryAfter reads Retry-After header which is either seconds or a date
+func ParseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
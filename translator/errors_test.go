@@ -0,0 +1,93 @@
+package translator
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *http.Response
+		body string
+		want error
+	}{
+		{
+			name: "rate limited",
+			resp: &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Status:     "429 Too Many Requests",
+				Header:     http.Header{"Retry-After": []string{"120"}},
+			},
+			body: "slow down\n",
+			want: RateLimitedError{RetryAfter: 2 * time.Minute, Message: "429 Too Many Requests - slow down"},
+		},
+		{
+			name: "forbidden",
+			resp: &http.Response{
+				StatusCode: http.StatusForbidden,
+				Status:     "403 Forbidden",
+			},
+			want: AuthFailedError{Message: "403 Forbidden"},
+		},
+		{
+			name: "unavailable for legal reasons",
+			resp: &http.Response{
+				StatusCode: http.StatusUnavailableForLegalReasons,
+				Status:     "451 Unavailable For Legal Reasons",
+			},
+			want: BlockedError{Message: "451 Unavailable For Legal Reasons"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StatusError(tt.resp, []byte(tt.body)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StatusError() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusError_Transient(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusBadGateway,
+		Status:     "502 Bad Gateway",
+	}
+
+	if _, ok := StatusError(resp, nil).(TransientError); !ok {
+		t.Errorf("StatusError() = %T, want TransientError", StatusError(resp, nil))
+	}
+}
+
+func TestScraperStatusError(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *http.Response
+		want error
+	}{
+		{
+			name: "forbidden",
+			resp: &http.Response{StatusCode: http.StatusForbidden, Status: "403 Forbidden"},
+			want: BlockedError{Message: "403 Forbidden"},
+		},
+		{
+			name: "unauthorized",
+			resp: &http.Response{StatusCode: http.StatusUnauthorized, Status: "401 Unauthorized"},
+			want: BlockedError{Message: "401 Unauthorized"},
+		},
+		{
+			name: "rate limited",
+			resp: &http.Response{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"},
+			want: RateLimitedError{Message: "429 Too Many Requests"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScraperStatusError(tt.resp, nil); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScraperStatusError() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
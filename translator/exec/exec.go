@@ -0,0 +1,272 @@
+// Package exec runs translation engines as external processes that talk line delimited JSON over stdin and stdout.
+//
+// Plugin starts by writing a hello line, empty pairs means every pair is supported
+// and batch size of 0 or 1 sends one text at a time:
+//
+//	{"name": "argos", "pairs": [{"from": "ja", "to": "en"}], "batch_size": 16}
+//
+// After that every request is a single line and gets answered with a line carrying the same id, in any order:
+//
+//	{"id": 1, "from": "ja", "to": "en", "texts": ["おはよう"]}
+//	{"id": 1, "translations": ["Good morning"]}
+//	{"id": 1, "error": "model isn't loaded yet", "kind": "transient"}
+//
+// Kind is optional and one of rate_limited, unsupported, transient or blocked.
+// Anything written to stderr is logged and plugin should exit once stdin is closed.
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+// Type is the value of Type in translator config that creates a plugin engine
+const Type = "exec"
+
+const (
+	defaultTimeout = time.Minute
+	batchWindow    = 50 * time.Millisecond
+
+	// Crashing plugin is started again after a second, waiting twice as long every time it fails right away
+	minRestartDelay = time.Second
+	maxRestartDelay = time.Minute
+)
+
+// Translate is a translation engine running as a separate process, every instance is named after its config section
+type Translate struct {
+	name    string
+	enabled bool
+	batcher *translator.Batcher
+
+	command      []string
+	timeout      time.Duration
+	restartDelay time.Duration
+	nextID       uint64
+
+	lock  sync.Mutex
+	proc  *process // nil while plugin is restarting
+	pairs map[pair]bool
+}
+
+type pair struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type hello struct {
+	Name      string `json:"name"`
+	Pairs     []pair `json:"pairs"`
+	BatchSize int    `json:"batch_size"`
+}
+
+type request struct {
+	ID    uint64   `json:"id"`
+	From  string   `json:"from"`
+	To    string   `json:"to"`
+	Texts []string `json:"texts"`
+}
+
+type response struct {
+	ID           uint64   `json:"id"`
+	Translations []string `json:"translations"`
+	Error        string   `json:"error"`
+	Kind         string   `json:"kind"`
+}
+
+// err turns error reported by plugin into matching error type
+func (r response) err(from, to string) error {
+	switch r.Kind {
+	case "rate_limited":
+		return translator.RateLimitedError{Message: r.Error}
+	case "unsupported":
+		return translator.UnsupportedError{From: from, To: to, Message: r.Error}
+	case "transient":
+		return translator.TransientError{Err: errors.New(r.Error)}
+	case "blocked":
+		return translator.BlockedError{Message: r.Error}
+	}
+
+	return errors.New(r.Error)
+}
+
+func New(name string) *Translate {
+	return &Translate{
+		name:         name,
+		restartDelay: minRestartDelay,
+	}
+}
+
+func (t *Translate) Name() string {
+	return t.name
+}
+
+func (t *Translate) Start(c config.TranslatorConfig) error {
+	if len(c.Command) < 1 {
+		return fmt.Errorf("%s: Command is required", t.name)
+	}
+
+	t.command = c.Command
+
+	t.timeout = c.Timeout.Duration()
+	if t.timeout <= 0 {
+		t.timeout = defaultTimeout
+	}
+
+	p, err := t.launch()
+	if err != nil {
+		return fmt.Errorf("%s: Failed to start plugin: %v", t.name, err)
+	}
+
+	t.setProcess(p)
+
+	batchSize := c.BatchSize
+	if batchSize <= 0 {
+		batchSize = p.hello.BatchSize
+	}
+
+	if batchSize > 1 {
+		t.batcher = translator.NewBatcher(t.translateBatch, batchWindow, batchSize, 0)
+	}
+
+	log.Infof("%s: Plugin %q started with %d supported pairs and batch size %d", t.name, p.hello.Name, len(p.hello.Pairs), batchSize)
+
+	go t.supervise(p)
+
+	t.enabled = true
+
+	return nil
+}
+
+func (t *Translate) Enabled() bool {
+	return t.enabled
+}
+
+// Supports reports whether plugin said it can translate language pair
+func (t *Translate) Supports(from, to string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return len(t.pairs) == 0 || t.pairs[pair{From: from, To: to}]
+}
+
+func (t *Translate) Translate(req *translator.Request) (string, error) {
+	if !t.Supports(req.From, req.To) {
+		return "", translator.UnsupportedError{From: req.From, To: req.To, Message: "plugin doesn't support it"}
+	}
+
+	if t.batcher != nil {
+		return t.batcher.Translate(req)
+	}
+
+	out, err := t.translateBatch(req.From, req.To, []string{req.Text})
+	if err != nil {
+		return "", err
+	}
+
+	if len(out) != 1 {
+		return "", fmt.Errorf("plugin returned %d translations for 1 text", len(out))
+	}
+
+	return out[0], nil
+}
+
+func (t *Translate) translateBatch(from, to string, texts []string) ([]string, error) {
+	start := time.Now()
+
+	t.lock.Lock()
+	p := t.proc
+	t.lock.Unlock()
+
+	if p == nil {
+		return nil, translator.TransientError{Err: errors.New("plugin is restarting")}
+	}
+
+	resp, err := p.call(request{
+		ID:    atomic.AddUint64(&t.nextID, 1),
+		From:  from,
+		To:    to,
+		Texts: texts,
+	}, t.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Error) > 0 {
+		return nil, resp.err(from, to)
+	}
+
+	log.WithFields(log.Fields{
+		"time":  time.Since(start),
+		"texts": len(texts),
+	}).Debugf("%s: %q", t.name, resp.Translations)
+
+	return resp.Translations, nil
+}
+
+func (t *Translate) launch() (*process, error) {
+	// Plugin has to load its models before saying hello, that's allowed to take as long as a request
+	return startProcess(t.name, t.command, t.timeout)
+}
+
+func (t *Translate) setProcess(p *process) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.proc = p
+
+	if p == nil {
+		return
+	}
+
+	t.pairs = make(map[pair]bool)
+	for _, pr := range p.hello.Pairs {
+		t.pairs[pr] = true
+	}
+}
+
+// supervise starts plugin again whenever it exits
+func (t *Translate) supervise(p *process) {
+	delay := t.restartDelay
+
+	for {
+		<-p.done
+
+		t.setProcess(nil)
+
+		// Plugin that ran for a while before crashing gets restarted quickly again
+		if time.Since(p.started) > maxRestartDelay {
+			delay = t.restartDelay
+		}
+
+		log.Warnf("%s: Plugin exited (%v), restarting in %s", t.name, p.err, delay)
+
+		for {
+			time.Sleep(delay)
+
+			if delay *= 2; delay > maxRestartDelay {
+				delay = maxRestartDelay
+			}
+
+			np, err := t.launch()
+			if err != nil {
+				log.Errorf("%s: Failed to restart plugin: %v", t.name, err)
+				continue
+			}
+
+			log.Infof("%s: Plugin restarted", t.name)
+
+			t.setProcess(np)
+			p = np
+
+			break
+		}
+	}
+}
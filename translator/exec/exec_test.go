@@ -0,0 +1,199 @@
+package exec
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+// TestHelperProcess acts as a plugin when test binary is started by the tests below
+func TestHelperProcess(t *testing.T) {
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+
+	if len(args) < 2 {
+		return
+	}
+
+	runPlugin(args[1])
+	os.Exit(0)
+}
+
+// runPlugin translates ja to en by prefixing texts, special texts make it misbehave
+func runPlugin(mode string) {
+	if mode == "mute" {
+		time.Sleep(time.Minute)
+		return
+	}
+
+	var lock sync.Mutex
+	out := json.NewEncoder(os.Stdout)
+
+	out.Encode(hello{Name: "test", Pairs: []pair{{From: "ja", To: "en"}}, BatchSize: 4})
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			os.Stderr.WriteString("bad request\n")
+			continue
+		}
+
+		go func() {
+			resp := response{ID: req.ID}
+
+			switch req.Texts[0] {
+			case "crash":
+				os.Exit(1)
+			case "slow":
+				time.Sleep(time.Second)
+			case "busy":
+				resp.Error = "too many requests"
+				resp.Kind = "rate_limited"
+			}
+
+			if len(resp.Error) < 1 {
+				for _, text := range req.Texts {
+					resp.Translations = append(resp.Translations, req.To+": "+text)
+				}
+			}
+
+			lock.Lock()
+			out.Encode(resp)
+			lock.Unlock()
+		}()
+	}
+}
+
+func newTestTranslate(t *testing.T, mode string, conf config.TranslatorConfig) *Translate {
+	conf.Command = []string{os.Args[0], "-test.run=^TestHelperProcess$", "--", mode}
+
+	tr := New("Test")
+	if err := tr.Start(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	return tr
+}
+
+func TestTranslate_Translate(t *testing.T) {
+	tr := newTestTranslate(t, "echo", config.TranslatorConfig{})
+
+	if !tr.Supports("ja", "en") || tr.Supports("ja", "ru") {
+		t.Error("Supports() doesn't match pairs from hello")
+	}
+
+	texts := []string{"一", "二", "三", "四", "五"}
+	results := make([]string, len(texts))
+
+	var wg sync.WaitGroup
+	for i := range texts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			var err error
+			results[i], err = tr.Translate(&translator.Request{Text: texts[i], From: "ja", To: "en"})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range texts {
+		if want := "en: " + texts[i]; results[i] != want {
+			t.Errorf("Translate(%q) = %q, want %q", texts[i], results[i], want)
+		}
+	}
+
+	_, err := tr.Translate(&translator.Request{Text: "一", From: "ja", To: "ru"})
+	if !errors.As(err, &translator.UnsupportedError{}) {
+		t.Errorf("Translate() error = %#v, want UnsupportedError", err)
+	}
+
+	_, err = tr.Translate(&translator.Request{Text: "busy", From: "ja", To: "en"})
+	if !errors.As(err, &translator.RateLimitedError{}) {
+		t.Errorf("Translate() error = %#v, want RateLimitedError", err)
+	}
+}
+
+func TestTranslate_Timeout(t *testing.T) {
+	tr := newTestTranslate(t, "echo", config.TranslatorConfig{
+		BatchSize: 1,
+		Timeout:   config.Duration(200 * time.Millisecond),
+	})
+
+	_, err := tr.Translate(&translator.Request{Text: "slow", From: "ja", To: "en"})
+	if !errors.As(err, &translator.TransientError{}) {
+		t.Errorf("Translate() error = %#v, want TransientError", err)
+	}
+
+	out, err := tr.Translate(&translator.Request{Text: "一", From: "ja", To: "en"})
+	if err != nil || out != "en: 一" {
+		t.Errorf("Translate() after timeout = %q, %v", out, err)
+	}
+}
+
+func TestTranslate_Restart(t *testing.T) {
+	tr := New("Test")
+	tr.restartDelay = 10 * time.Millisecond
+
+	err := tr.Start(config.TranslatorConfig{
+		Command:   []string{os.Args[0], "-test.run=^TestHelperProcess$", "--", "echo"},
+		BatchSize: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tr.Translate(&translator.Request{Text: "crash", From: "ja", To: "en"})
+	if !errors.As(err, &translator.TransientError{}) {
+		t.Errorf("Translate() error = %#v, want TransientError", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+
+	for {
+		out, err := tr.Translate(&translator.Request{Text: "一", From: "ja", To: "en"})
+		if err == nil {
+			if out != "en: 一" {
+				t.Errorf("Translate() after restart = %q", out)
+			}
+
+			break
+		}
+
+		if time.Now().After(deadline) || !strings.Contains(err.Error(), "plugin") {
+			t.Fatalf("plugin didn't come back: %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTranslate_Start(t *testing.T) {
+	if err := New("Test").Start(config.TranslatorConfig{}); err == nil {
+		t.Error("expected error without command")
+	}
+
+	tr := New("Test")
+
+	err := tr.Start(config.TranslatorConfig{
+		Command: []string{os.Args[0], "-test.run=^TestHelperProcess$", "--", "mute"},
+		Timeout: config.Duration(200 * time.Millisecond),
+	})
+	if err == nil || tr.Enabled() {
+		t.Error("expected error when plugin doesn't say hello")
+	}
+}
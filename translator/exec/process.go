@@ -0,0 +1,208 @@
+package exec
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+// Batches of long lines can get big
+const maxLineSize = 16 * 1024 * 1024
+
+// process is a single run of plugin, it is replaced by a new one when it exits
+type process struct {
+	name    string
+	cmd     *exec.Cmd
+	hello   hello
+	started time.Time
+
+	writeLock sync.Mutex
+	stdin     io.WriteCloser
+
+	lock    sync.Mutex
+	pending map[uint64]chan response
+	exited  bool
+	err     error         // Why process exited
+	done    chan struct{} // Closed once process has exited
+}
+
+// startProcess runs command and waits until it says hello
+func startProcess(name string, command []string, timeout time.Duration) (*process, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &process{
+		name:    name,
+		cmd:     cmd,
+		started: time.Now(),
+		stdin:   stdin,
+		pending: make(map[uint64]chan response),
+		done:    make(chan struct{}),
+	}
+
+	hellos := make(chan error, 1)
+
+	go p.read(stdout, stderr, hellos)
+
+	select {
+	case err := <-hellos:
+		if err != nil {
+			cmd.Process.Kill()
+			return nil, err
+		}
+	case <-p.done:
+		return nil, fmt.Errorf("exited before saying hello: %v", p.err)
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		return nil, fmt.Errorf("didn't say hello in %s", timeout)
+	}
+
+	return p, nil
+}
+
+// read handles everything plugin writes until it exits
+func (p *process) read(stdout, stderr io.Reader, hellos chan<- error) {
+	logged := make(chan struct{})
+
+	go func() {
+		defer close(logged)
+
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			log.Infof("%s: %s", p.name, scanner.Text())
+		}
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	greeted := false
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		if !greeted {
+			greeted = true
+
+			if err := json.Unmarshal(line, &p.hello); err != nil {
+				hellos <- fmt.Errorf("invalid hello %q: %v", line, err)
+			} else {
+				hellos <- nil
+			}
+
+			continue
+		}
+
+		var resp response
+		if err := json.Unmarshal(line, &resp); err != nil {
+			log.Warnf("%s: Invalid response %q: %v", p.name, line, err)
+			continue
+		}
+
+		p.lock.Lock()
+		ch, ok := p.pending[resp.ID]
+		delete(p.pending, resp.ID)
+		p.lock.Unlock()
+
+		if !ok {
+			log.Debugf("%s: Dropped response to request %d that already timed out", p.name, resp.ID)
+			continue
+		}
+
+		ch <- resp
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Errorf("%s: Failed to read plugin output: %v", p.name, err)
+		p.cmd.Process.Kill()
+	}
+
+	<-logged
+
+	err := p.cmd.Wait()
+	if err == nil {
+		err = fmt.Errorf("exit status 0")
+	}
+
+	p.lock.Lock()
+	p.exited = true
+	p.err = err
+	for id, ch := range p.pending {
+		ch <- response{ID: id, Error: "plugin exited: " + err.Error(), Kind: "transient"}
+	}
+	p.pending = nil
+	p.lock.Unlock()
+
+	close(p.done)
+}
+
+// call sends request to plugin and waits for the answer
+func (p *process) call(req request, timeout time.Duration) (response, error) {
+	ch := make(chan response, 1)
+
+	p.lock.Lock()
+	if p.exited {
+		p.lock.Unlock()
+		return response{}, translator.TransientError{Err: fmt.Errorf("plugin exited: %v", p.err)}
+	}
+	p.pending[req.ID] = ch
+	p.lock.Unlock()
+
+	line, err := json.Marshal(req)
+	if err != nil {
+		p.forget(req.ID)
+		return response{}, err
+	}
+
+	p.writeLock.Lock()
+	_, err = p.stdin.Write(append(line, '\n'))
+	p.writeLock.Unlock()
+
+	if err != nil {
+		p.forget(req.ID)
+		return response{}, translator.TransientError{Err: fmt.Errorf("failed to write request: %v", err)}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-timer.C:
+		p.forget(req.ID)
+		return response{}, translator.TransientError{Err: fmt.Errorf("no answer from plugin in %s", timeout)}
+	}
+}
+
+func (p *process) forget(id uint64) {
+	p.lock.Lock()
+	delete(p.pending, id)
+	p.lock.Unlock()
+}
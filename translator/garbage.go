@@ -0,0 +1,36 @@
+package translator
+
+import (
+	"strings"
+	"unicode"
+)
+
+// IsTranslationGarbage reports whether translation into a non-japanese language is junk or left mostly untranslated
+func IsTranslationGarbage(text string) bool {
+	text = strings.ToLower(text)
+	if strings.Contains(text, "powered by discuz") || strings.Contains(text, "powered by translate") {
+		return true
+	}
+
+	var rest int
+	var japanese int
+
+	for _, r := range text {
+		if unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Han, r) {
+			japanese++
+			continue
+		}
+
+		if unicode.IsSpace(r) {
+			continue
+		}
+
+		rest++
+	}
+
+	if japanese > rest {
+		return true
+	}
+
+	return false
+}
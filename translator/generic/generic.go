@@ -0,0 +1,224 @@
+package generic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+// Type is the value of Type in translator config that creates a generic engine
+const Type = "generic"
+
+const (
+	timeout     = 30 * time.Second
+	batchWindow = 50 * time.Millisecond
+)
+
+// Translate is a translation engine described entirely by config, every instance is named after its config section
+type Translate struct {
+	name    string
+	enabled bool
+	client  *http.Client
+	batcher *translator.Batcher
+
+	method    string
+	url       *template.Template
+	headers   map[string]*template.Template
+	body      *template.Template
+	result    path
+	batch     bool
+	rateLimit map[int]bool
+	key       string
+}
+
+// templateData is what request templates have access to
+type templateData struct {
+	Text  string // First text, only one unless batching
+	Texts []string
+	From  string
+	To    string
+	Key   string
+}
+
+var funcs = template.FuncMap{
+	// json writes value as JSON, strings come out quoted and escaped
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join": strings.Join,
+}
+
+func New(name string) *Translate {
+	return &Translate{
+		name:   name,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (t *Translate) Name() string {
+	return t.name
+}
+
+func (t *Translate) Start(c config.TranslatorConfig) error {
+	var err error
+
+	if len(c.URL) < 1 {
+		return fmt.Errorf("%s: URL is required", t.name)
+	}
+
+	t.url, err = template.New("url").Funcs(funcs).Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("%s: Invalid URL template: %v", t.name, err)
+	}
+
+	t.body, err = template.New("body").Funcs(funcs).Parse(c.Body)
+	if err != nil {
+		return fmt.Errorf("%s: Invalid body template: %v", t.name, err)
+	}
+
+	t.headers = make(map[string]*template.Template)
+	for name, value := range c.Headers {
+		t.headers[name], err = template.New(name).Funcs(funcs).Parse(value)
+		if err != nil {
+			return fmt.Errorf("%s: Invalid template for header %s: %v", t.name, name, err)
+		}
+	}
+
+	t.result, err = parsePath(c.Result)
+	if err != nil {
+		return fmt.Errorf("%s: Invalid result expression: %v", t.name, err)
+	}
+
+	t.method = strings.ToUpper(c.Method)
+	if len(t.method) < 1 {
+		t.method = "POST"
+	}
+
+	t.rateLimit = map[int]bool{http.StatusTooManyRequests: true}
+	for _, status := range c.RateLimitStatus {
+		t.rateLimit[status] = true
+	}
+
+	t.key = c.Key
+
+	t.batch = c.BatchSize > 1
+	if t.batch {
+		t.batcher = translator.NewBatcher(t.translateBatch, batchWindow, c.BatchSize, 0)
+	}
+
+	t.enabled = true
+
+	return nil
+}
+
+func (t *Translate) Enabled() bool {
+	return t.enabled
+}
+
+func (t *Translate) Translate(req *translator.Request) (string, error) {
+	if t.batch {
+		return t.batcher.Translate(req)
+	}
+
+	out, err := t.translateBatch(req.From, req.To, []string{req.Text})
+	if err != nil {
+		return "", err
+	}
+
+	return out[0], nil
+}
+
+func (t *Translate) translateBatch(from, to string, texts []string) ([]string, error) {
+	data := templateData{
+		Text:  texts[0],
+		Texts: texts,
+		From:  from,
+		To:    to,
+		Key:   t.key,
+	}
+
+	url, err := execute(t.url, data)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := execute(t.body, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader
+	if len(body) > 0 {
+		reader = strings.NewReader(body)
+	}
+
+	r, err := http.NewRequest(t.method, url, reader)
+	if err != nil {
+		log.Errorln("Failed to create request", err)
+		return nil, err
+	}
+
+	// Most services take JSON, headers from config can say otherwise
+	if reader != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	for name, tmpl := range t.headers {
+		value, err := execute(tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Header.Set(name, value)
+	}
+
+	var response interface{}
+	if err := translator.Do(t.client, r, nil, &response, t.statusError); err != nil {
+		return nil, err
+	}
+
+	out, err := t.result.strings(response)
+	if err != nil {
+		return nil, fmt.Errorf("translation not found in response: %v", err)
+	}
+
+	// Services without batching may still split longer text in parts
+	if !t.batch {
+		out = []string{strings.Join(out, "")}
+	}
+
+	return out, nil
+}
+
+// statusError treats configured status codes as rate limiting on top of the usual ones
+func (t *Translate) statusError(resp *http.Response, body []byte) error {
+	if t.rateLimit[resp.StatusCode] {
+		return translator.RateLimitedError{
+			RetryAfter: translator.ParseRetryAfter(resp.Header.Get("Retry-After")),
+			Message:    fmt.Sprintf("%s - %s", resp.Status, bytes.TrimSpace(body)),
+		}
+	}
+
+	return translator.StatusError(resp, body)
+}
+
+func execute(tmpl *template.Template, data templateData) (string, error) {
+	var b strings.Builder
+
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to fill %s template: %v", tmpl.Name(), err)
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,165 @@
+package generic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+	"gitgud.io/softashell/comfy-translator/translator/translatortest"
+)
+
+func newTestTranslate(t *testing.T, conf config.TranslatorConfig, h http.HandlerFunc) *Translate {
+	tr := New("Test")
+	translatortest.Start(t, tr, conf, h)
+
+	return tr
+}
+
+func TestTranslate_Translate(t *testing.T) {
+	tr := newTestTranslate(t, config.TranslatorConfig{
+		Key:     "secret",
+		Method:  "get",
+		URL:     "/translate?sl={{.From}}&tl={{.To}}&q={{urlquery .Text}}",
+		Headers: map[string]string{"Authorization": "Bearer {{.Key}}"},
+		Result:  "[0][*][0]",
+	}, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("method = %q", r.Method)
+		}
+
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+
+		q := r.URL.Query()
+		if q.Get("sl") != "ja" || q.Get("tl") != "en" || q.Get("q") != "おはよう & こんにちは" {
+			t.Errorf("query = %v", q)
+		}
+
+		fmt.Fprint(w, `[[["Good morning ", "おはよう "], ["& hello", "& こんにちは"]]]`)
+	})
+
+	out, err := tr.Translate(&translator.Request{Text: "おはよう & こんにちは", From: "ja", To: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out != "Good morning & hello" {
+		t.Errorf("Translate() = %q", out)
+	}
+}
+
+func TestTranslate_Batch(t *testing.T) {
+	var lock sync.Mutex
+	var batches [][]string
+
+	tr := newTestTranslate(t, config.TranslatorConfig{
+		BatchSize: 10,
+		Body:      `{"q": {{json .Texts}}, "source": {{json .From}}, "target": {{json .To}}}`,
+		Result:    "$.translations[*].text",
+	}, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+
+		var body struct {
+			Q      []string `json:"q"`
+			Source string   `json:"source"`
+			Target string   `json:"target"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+
+		lock.Lock()
+		batches = append(batches, body.Q)
+		lock.Unlock()
+
+		var resp struct {
+			Translations []struct {
+				Text string `json:"text"`
+			} `json:"translations"`
+		}
+		for _, q := range body.Q {
+			resp.Translations = append(resp.Translations, struct {
+				Text string `json:"text"`
+			}{q + " " + body.Target})
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	translatortest.Batched(t, tr, []string{"一", "\"二\"", "三"}, func(text string) string { return text + " en" }, func() int {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return len(batches)
+	})
+}
+
+func TestTranslate_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   interface{}
+	}{
+		{"configured rate limit", http.StatusServiceUnavailable, `{"error": "slow down"}`, &translator.RateLimitedError{}},
+		{"default rate limit", http.StatusTooManyRequests, `{"error": "slow down"}`, &translator.RateLimitedError{}},
+		{"bad key", http.StatusForbidden, `{"error": "bad key"}`, &translator.AuthFailedError{}},
+		{"server error", http.StatusInternalServerError, `{"error": "oops"}`, &translator.TransientError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestTranslate(t, config.TranslatorConfig{
+				Result:          "text",
+				RateLimitStatus: []int{http.StatusServiceUnavailable},
+			}, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+
+			_, err := tr.Translate(&translator.Request{Text: "おはよう", From: "ja", To: "en"})
+			if !errors.As(err, tt.want) {
+				t.Errorf("Translate() error = %#v, want %T", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing result", func(t *testing.T) {
+		tr := newTestTranslate(t, config.TranslatorConfig{Result: "text"}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"message": "no text here"}`)
+		})
+
+		if _, err := tr.Translate(&translator.Request{Text: "おはよう", From: "ja", To: "en"}); err == nil {
+			t.Error("expected error when translation isn't in response")
+		}
+	})
+}
+
+func TestTranslate_Start(t *testing.T) {
+	tests := []struct {
+		name string
+		conf config.TranslatorConfig
+	}{
+		{"no url", config.TranslatorConfig{Result: "text"}},
+		{"no result", config.TranslatorConfig{URL: "http://localhost/"}},
+		{"broken body", config.TranslatorConfig{URL: "http://localhost/", Result: "text", Body: "{{.Text"}},
+		{"broken header", config.TranslatorConfig{URL: "http://localhost/", Result: "text", Headers: map[string]string{"X-Key": "{{.Key"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := New("Test")
+			if err := tr.Start(tt.conf); err == nil || tr.Enabled() {
+				t.Error("expected Start() to fail")
+			}
+		})
+	}
+}
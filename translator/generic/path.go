@@ -0,0 +1,143 @@
+package generic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// path is a parsed JSONPath like expression, for example $.data.translations[*].translatedText or [0][0][0].
+// Only keys, array indexes and [*] for every array item are supported
+type path []segment
+
+type segment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func parsePath(expr string) (path, error) {
+	expr = strings.TrimSpace(expr)
+	expr = strings.TrimPrefix(expr, "$")
+
+	if len(expr) == 0 {
+		return nil, fmt.Errorf("empty result path")
+	}
+
+	var p path
+
+	for i := 0; i < len(expr); {
+		switch expr[i] {
+		case '.':
+			i++
+		case '[':
+			end := strings.IndexByte(expr[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ] in %q", expr)
+			}
+
+			inner := strings.TrimSpace(expr[i+1 : i+end])
+			i += end + 1
+
+			switch {
+			case inner == "*":
+				p = append(p, segment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				p = append(p, segment{key: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid index %q in %q", inner, expr)
+				}
+
+				p = append(p, segment{index: n, isIndex: true})
+			}
+
+			continue
+		}
+
+		// Key goes until next . or [
+		end := strings.IndexAny(expr[i:], ".[")
+		if end < 0 {
+			end = len(expr) - i
+		}
+
+		if end == 0 {
+			continue
+		}
+
+		p = append(p, segment{key: expr[i : i+end]})
+		i += end
+	}
+
+	if len(p) == 0 {
+		return nil, fmt.Errorf("empty result path")
+	}
+
+	return p, nil
+}
+
+// strings returns every string path points to, arrays of strings at the end are flattened
+func (p path) strings(v interface{}) ([]string, error) {
+	values := []interface{}{v}
+
+	for _, s := range p {
+		var next []interface{}
+
+		for _, v := range values {
+			switch {
+			case s.wildcard:
+				arr, ok := v.([]interface{})
+				if !ok {
+					return nil, fmt.Errorf("expected array for [*], got %T", v)
+				}
+
+				next = append(next, arr...)
+			case s.isIndex:
+				arr, ok := v.([]interface{})
+				if !ok || s.index >= len(arr) {
+					return nil, fmt.Errorf("index [%d] not found", s.index)
+				}
+
+				next = append(next, arr[s.index])
+			default:
+				obj, ok := v.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("key %q not found", s.key)
+				}
+
+				value, ok := obj[s.key]
+				if !ok {
+					return nil, fmt.Errorf("key %q not found", s.key)
+				}
+
+				next = append(next, value)
+			}
+		}
+
+		values = next
+	}
+
+	var out []string
+
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			out = append(out, v)
+		case []interface{}:
+			for _, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("expected string, got %T", item)
+				}
+
+				out = append(out, s)
+			}
+		default:
+			return nil, fmt.Errorf("expected string, got %T", v)
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,59 @@
+package generic
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPath_Strings(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{"key", "translatedText", `{"translatedText": "Hello"}`, []string{"Hello"}, false},
+		{"root prefix", "$.data.text", `{"data": {"text": "Hello"}}`, []string{"Hello"}, false},
+		{"index", "$.translations[0].text", `{"translations": [{"text": "Hello"}, {"text": "World"}]}`, []string{"Hello"}, false},
+		{"wildcard", "$.translations[*].text", `{"translations": [{"text": "Hello"}, {"text": "World"}]}`, []string{"Hello", "World"}, false},
+		{"array at the end", "$.text", `{"text": ["Hello", "World"]}`, []string{"Hello", "World"}, false},
+		{"top level array", "[0][*][0]", `[[["Hello, ", "こんにちは、"], ["world", "世界"]]]`, []string{"Hello, ", "world"}, false},
+		{"quoted key", `$["translated-text"]`, `{"translated-text": "Hello"}`, []string{"Hello"}, false},
+		{"missing key", "$.text", `{"error": "nope"}`, nil, true},
+		{"index out of range", "$.translations[1]", `{"translations": ["Hello"]}`, nil, true},
+		{"not a string", "$.code", `{"code": 200}`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parsePath(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var v interface{}
+			if err := json.Unmarshal([]byte(tt.body), &v); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := p.strings(v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("strings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("strings() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePath_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "$", "$.text[", "$.text[-1]", "$.text[abc]"} {
+		if _, err := parsePath(expr); err == nil {
+			t.Errorf("parsePath(%q) expected error", expr)
+		}
+	}
+}
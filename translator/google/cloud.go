@@ -0,0 +1,131 @@
+package google
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+const (
+	cloudURL     = "https://translation.googleapis.com/language/translate/v2"
+	cloudTimeout = 10 * time.Second
+
+	// https://cloud.google.com/translate/quotas, 5000 characters is the recommended request size
+	cloudMaxTexts  = 128
+	cloudMaxLength = 5000
+
+	// Paid api doesn't need requests spaced out, only a moment to collect lines arriving together
+	cloudBatchWindow = 50 * time.Millisecond
+)
+
+// https://cloud.google.com/translate/docs/reference/rest/v2/translate
+type cloudRequest struct {
+	Q      []string `json:"q"`
+	Source string   `json:"source"`
+	Target string   `json:"target"`
+	Format string   `json:"format"`
+}
+
+type cloudResponse struct {
+	Data struct {
+		Translations []struct {
+			TranslatedText string `json:"translatedText"`
+		} `json:"translations"`
+	} `json:"data"`
+}
+
+type cloudError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Errors  []struct {
+			Reason string `json:"reason"`
+		} `json:"errors"`
+	} `json:"error"`
+}
+
+// cloudStatusError maps api errors, reason tells apart per minute and per day quotas which both come as 403
+func cloudStatusError(from, to string) translator.StatusErrorFunc {
+	return func(resp *http.Response, body []byte) error {
+		var e cloudError
+		if err := json.Unmarshal(body, &e); err != nil {
+			return translator.StatusError(resp, body)
+		}
+
+		msg := fmt.Sprintf("%s - %s", resp.Status, e.Error.Message)
+
+		for _, reason := range e.Error.Errors {
+			switch reason.Reason {
+			case "rateLimitExceeded", "userRateLimitExceeded":
+				return translator.RateLimitedError{
+					RetryAfter: translator.ParseRetryAfter(resp.Header.Get("Retry-After")),
+					Message:    msg,
+				}
+			case "dailyLimitExceeded", "quotaExceeded", "accessNotConfigured", "billingNotEnabled":
+				return translator.BlockedError{Message: msg}
+			case "keyInvalid":
+				return translator.AuthFailedError{Message: msg}
+			}
+		}
+
+		if resp.StatusCode == http.StatusBadRequest {
+			if strings.Contains(strings.ToLower(e.Error.Message), "language pair") {
+				return translator.UnsupportedError{From: from, To: to, Message: msg}
+			}
+
+			// Invalid keys come as bad request too
+			if strings.Contains(e.Error.Message, "API key not valid") {
+				return translator.AuthFailedError{Message: msg}
+			}
+		}
+
+		return translator.StatusError(resp, body)
+	}
+}
+
+func (t *Translate) startCloud(c config.TranslatorConfig) error {
+	t.cloudURL = c.URL
+	if len(t.cloudURL) < 1 {
+		t.cloudURL = cloudURL
+	}
+
+	t.apiKey = c.Key
+	t.cloudClient = &http.Client{Timeout: cloudTimeout}
+	t.batcher = translator.NewBatcher(t.translateCloud, cloudBatchWindow, cloudMaxTexts, cloudMaxLength)
+
+	t.cloud = true
+	t.enabled = true
+
+	return nil
+}
+
+// translateCloud translates texts with Cloud Translation api in one request
+func (t *Translate) translateCloud(from, to string, texts []string) ([]string, error) {
+	req := cloudRequest{
+		Q:      texts,
+		Source: from,
+		Target: to,
+		Format: "text",
+	}
+
+	// Key in query would end up in logged and cached request errors
+	header := http.Header{"X-Goog-Api-Key": {t.apiKey}}
+
+	var response cloudResponse
+	if err := translator.PostJSON(t.cloudClient, t.cloudURL, header, req, &response, cloudStatusError(from, to)); err != nil {
+		return nil, err
+	}
+
+	out := make([]string, len(response.Data.Translations))
+	for i := range response.Data.Translations {
+		out[i] = response.Data.Translations[i].TranslatedText
+	}
+
+	return out, nil
+}
@@ -0,0 +1,115 @@
+package google
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+	"gitgud.io/softashell/comfy-translator/translator/translatortest"
+)
+
+func newTestCloud(t *testing.T, h http.HandlerFunc) *Translate {
+	tr := New()
+	translatortest.Start(t, tr, config.TranslatorConfig{Key: "secret"}, h)
+
+	return tr
+}
+
+func TestTranslate_Cloud(t *testing.T) {
+	var lock sync.Mutex
+	var batches int
+
+	tr := newTestCloud(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Goog-Api-Key") != "secret" {
+			t.Errorf("headers = %v", r.Header)
+		}
+
+		if strings.Contains(r.URL.String(), "secret") {
+			t.Errorf("key leaked into url %s", r.URL)
+		}
+
+		var req cloudRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+
+		if req.Source != "ja" || req.Target != "en" || req.Format != "text" {
+			t.Errorf("unexpected request %+v", req)
+		}
+
+		lock.Lock()
+		batches++
+		lock.Unlock()
+
+		var resp cloudResponse
+		for _, q := range req.Q {
+			resp.Data.Translations = append(resp.Data.Translations, struct {
+				TranslatedText string `json:"translatedText"`
+			}{"en " + q})
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	translatortest.Batched(t, tr, []string{"一", "二", "三"}, func(text string) string { return "en " + text }, func() int {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return batches
+	})
+
+	// Same cleanup applies to both modes
+	if out, err := tr.PostProcess(&translator.Request{Text: "邪魔しないで", From: "ja", To: "en"}, "Do n't bother me"); err != nil || out != "Don't bother me" {
+		t.Errorf("PostProcess() = %q, %v", out, err)
+	}
+}
+
+func TestTranslate_CloudErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   interface{}
+	}{
+		{"rate limited", http.StatusForbidden, `{"error": {"code": 403, "message": "User Rate Limit Exceeded", "errors": [{"reason": "userRateLimitExceeded"}]}}`, &translator.RateLimitedError{}},
+		{"daily quota", http.StatusForbidden, `{"error": {"code": 403, "message": "Daily Limit Exceeded", "errors": [{"reason": "dailyLimitExceeded"}]}}`, &translator.BlockedError{}},
+		{"bad key", http.StatusBadRequest, `{"error": {"code": 400, "message": "API key not valid. Please pass a valid API key.", "errors": [{"reason": "badRequest"}], "status": "INVALID_ARGUMENT"}}`, &translator.AuthFailedError{}},
+		{"bad language", http.StatusBadRequest, `{"error": {"code": 400, "message": "Bad language pair: ja|xx", "errors": [{"reason": "badRequest"}]}}`, &translator.UnsupportedError{}},
+		{"server error", http.StatusInternalServerError, `{"error": {"code": 500, "message": "Internal error"}}`, &translator.TransientError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestCloud(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+
+			_, err := tr.Translate(&translator.Request{Text: "おはよう", From: "ja", To: "xx"})
+			if !errors.As(err, tt.want) {
+				t.Errorf("Translate() error = %#v, want %T", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslate_CloudKeyNotInErrors(t *testing.T) {
+	srv := translatortest.NewServer(t, http.NotFoundHandler())
+	srv.Close()
+
+	tr := New()
+	if err := tr.Start(config.TranslatorConfig{Key: "secret", URL: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := tr.Translate(&translator.Request{Text: "おはよう", From: "ja", To: "en"})
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("Translate() error = %v, want error without key", err)
+	}
+}
@@ -8,10 +8,12 @@ import (
 )
 
 func dumpRequest(requestURL string, responseText string) {
-	f, err := os.OpenFile("translation-errors.txt", os.O_CREATE|os.O_APPEND, 0666)
+	f, err := os.OpenFile("translation-errors.txt", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
 	if err != nil {
-		log.Fatal(err)
+		log.Errorf("Failed to dump request: %v", err)
+		return
 	}
+	defer f.Close()
 
 	w := bufio.NewWriter(f)
 	w.WriteString(requestURL)
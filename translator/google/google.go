@@ -1,6 +1,7 @@
 package google
 
 import (
+	"net/http"
 	"regexp"
 	"strings"
 	"sync"
@@ -10,12 +11,12 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
-	"unicode"
-
 	"gitgud.io/softashell/comfy-translator/config"
 	"gitgud.io/softashell/comfy-translator/translator"
 )
 
+const postProcessVersion = 1
+
 const (
 	userAgent    = "Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko"
 	defaultDelay = time.Second * 7
@@ -36,6 +37,13 @@ type Translate struct {
 
 	delay time.Duration
 	batch *BatchTranslator
+
+	// Cloud Translation api is used instead of the free endpoint when key is set
+	cloud       bool
+	cloudClient *http.Client
+	cloudURL    string
+	apiKey      string
+	batcher     *translator.Batcher
 }
 
 func New() *Translate {
@@ -56,6 +64,10 @@ func (t *Translate) Name() string {
 }
 
 func (t *Translate) Start(c config.TranslatorConfig) error {
+	if len(c.Key) > 0 {
+		return t.startCloud(c)
+	}
+
 	t.enabled = true
 
 	return nil
@@ -68,13 +80,35 @@ func (t *Translate) Enabled() bool {
 func (t *Translate) Translate(req *translator.Request) (string, error) {
 	start := time.Now()
 
+	t.mutex.Lock()
 	t.lastRequest = time.Now()
+	t.mutex.Unlock()
 
-	out, err := t.batch.Join(req)
+	var out string
+	var err error
+
+	if t.cloud {
+		out, err = t.batcher.Translate(req)
+	} else {
+		out, err = t.batch.Join(req)
+	}
 	if err != nil {
 		return "", errors.Wrap(err, "Failed to process request")
 	}
 
+	log.WithFields(log.Fields{
+		"time": time.Since(start),
+	}).Debugf("Google: %q", out)
+
+	return out, nil
+}
+
+func (t *Translate) PostProcessVersion() int {
+	return postProcessVersion
+}
+
+// PostProcess removes garbage google likes to add and rejects translations that are beyond saving
+func (t *Translate) PostProcess(req *translator.Request, out string) (string, error) {
 	// Delete garbage output which often leaves the output empty, fix your shit google tbh
 	out2 := garbageRegex.ReplaceAllString(out, "")
 	if len(out) < 1 || (len(out2) < len(out)/2) {
@@ -86,17 +120,13 @@ func (t *Translate) Translate(req *translator.Request) (string, error) {
 
 	out = cleanText(out2)
 
-	if IsTranslationGarbage(out) {
+	if translator.IsTranslationGarbage(out) {
 		return "", translator.BadTranslationError{
 			Input:  req.Text,
 			Output: out,
 		}
 	}
 
-	log.WithFields(log.Fields{
-		"time": time.Since(start),
-	}).Debugf("Google: %q", out)
-
 	return out, nil
 }
 
@@ -122,32 +152,3 @@ func cleanText(text string) string {
 
 	return text
 }
-
-func IsTranslationGarbage(text string) bool {
-	text = strings.ToLower(text)
-	if strings.Contains(text, "powered by discuz") || strings.Contains(text, "powered by translate") {
-		return true
-	}
-
-	var rest int
-	var japanese int
-
-	for _, r := range text {
-		if unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Han, r) {
-			japanese++
-			continue
-		}
-
-		if unicode.IsSpace(r) {
-			continue
-		}
-
-		rest++
-	}
-
-	if japanese > rest {
-		return true
-	}
-
-	return false
-}
@@ -1,22 +1,33 @@
 package google
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/davecgh/go-spew/spew"
 	log "github.com/sirupsen/logrus"
 )
 
-func mergeOutput(input []inputObject, output []responsePair) []responsePair {
+// MergeError is returned when output can't be matched back to input lines
+type MergeError struct {
+	Reason string
+}
+
+func (e MergeError) Error() string {
+	return fmt.Sprintf("unable to merge output: %s", e.Reason)
+}
+
+func mergeOutput(input []inputObject, output []responsePair) ([]responsePair, error) {
 	inputCount := len(input)
 	outputCount := len(output)
 
 	if inputCount == 0 || outputCount == 0 {
-		return output
+		return output, nil
 	}
 
 	if inputCount > outputCount {
-		log.Fatal("Truncated output", spew.Sdump(input), spew.Sdump(output))
+		log.Debug("Truncated output", spew.Sdump(input), spew.Sdump(output))
+		return nil, MergeError{Reason: fmt.Sprintf("truncated output, got %d lines for %d inputs", outputCount, inputCount)}
 	}
 
 	for i := 0; i < outputCount-1; i++ {
@@ -34,7 +45,7 @@ func mergeOutput(input []inputObject, output []responsePair) []responsePair {
 		// TODO: Loop and handle more than one item join
 		next := i + 1
 		if next > outputCount-1 {
-			log.Fatal("output exhausted, unable to get proper results")
+			return nil, MergeError{Reason: "output exhausted"}
 		}
 
 		nextOut := output[next].input
@@ -44,7 +55,8 @@ func mergeOutput(input []inputObject, output []responsePair) []responsePair {
 			nextIn := input[next].req.Text
 
 			if nextIn == nextOut {
-				log.Fatalf("output has truncated input string\n%q == %q\n%s\n%s", nextIn, nextOut, spew.Sdump(input), spew.Sdump(output))
+				log.Debugf("output has truncated input string\n%q == %q\n%s\n%s", nextIn, nextOut, spew.Sdump(input), spew.Sdump(output))
+				return nil, MergeError{Reason: fmt.Sprintf("output has truncated input string %q", nextIn)}
 			}
 		}
 
@@ -71,5 +83,5 @@ func mergeOutput(input []inputObject, output []responsePair) []responsePair {
 		}
 	}
 
-	return output
+	return output, nil
 }
@@ -13,9 +13,10 @@ func Test_mergeOutput(t *testing.T) {
 		output []responsePair
 	}
 	tests := []struct {
-		name string
-		args args
-		want []responsePair
+		name    string
+		args    args
+		want    []responsePair
+		wantErr bool
 	}{
 		{
 			name: "input split on server side, delete last item",
@@ -209,10 +210,39 @@ func Test_mergeOutput(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "truncated output",
+			args: args{
+				input: []inputObject{
+					{
+						req: &translator.Request{
+							Text: "あっあっふあぁぁっ",
+						},
+					},
+					{
+						req: &translator.Request{
+							Text: "あっあっふあぁぁっ2",
+						},
+					},
+				},
+				output: []responsePair{
+					{
+						input:  "あっあっふあぁぁっ",
+						output: "Aaaaaaaaaa",
+					},
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := mergeOutput(tt.args.input, tt.args.output); !reflect.DeepEqual(got, tt.want) {
+			got, err := mergeOutput(tt.args.input, tt.args.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mergeOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("mergeOutput() = %v, want %v", got, tt.want)
 			}
 		})
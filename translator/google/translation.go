@@ -1,13 +1,13 @@
 package google
 
 import (
-	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"gitgud.io/softashell/comfy-translator/translator"
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -21,6 +21,8 @@ func buildClient() *retryablehttp.Client {
 	httpClient.RetryMax = 1
 	httpClient.RetryWaitMin = 30 * time.Second
 	httpClient.RetryWaitMax = 2 * time.Minute
+	// Return last response after retries so status can be checked
+	httpClient.ErrorHandler = retryablehttp.PassthroughErrorHandler
 
 	return httpClient
 }
@@ -91,18 +93,14 @@ func (q *BatchTranslator) translateItems(items []inputObject) error {
 
 	resp, err := q.client.Do(r)
 	if err != nil {
-		log.Fatal(err)
-
-		return errors.Wrapf(err, "Failed to do request (%s delay)", q.batchDelay)
+		return translator.TransientError{Err: errors.Wrapf(err, "Failed to do request (%s delay)", q.batchDelay)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		if contents, err := ioutil.ReadAll(resp.Body); err == nil {
-			return fmt.Errorf("%s - %s", resp.Status, contents)
-		}
+		contents, _ := ioutil.ReadAll(resp.Body)
 
-		return fmt.Errorf("%s", resp.Status)
+		return translator.ScraperStatusError(resp, contents)
 	}
 
 	contents, err := ioutil.ReadAll(resp.Body)
@@ -123,7 +121,12 @@ func (q *BatchTranslator) translateItems(items []inputObject) error {
 	attempt to merge them back into one */
 	if len(items) != len(response) {
 		log.Debug("Response pair count don't match input")
-		response = mergeOutput(items, response)
+
+		response, err = mergeOutput(items, response)
+		if err != nil {
+			dumpRequest(r.URL.RequestURI(), string(contents))
+			return err
+		}
 	}
 
 	for i, pair := range response {
@@ -0,0 +1,95 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StatusErrorFunc turns unsuccessful http response into error, engines use it for errors their api describes in body
+type StatusErrorFunc func(resp *http.Response, body []byte) error
+
+// PostJSON sends in as JSON to url and decodes JSON response into out
+func PostJSON(client *http.Client, url string, header http.Header, in, out interface{}, statusError StatusErrorFunc) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		log.Errorln("Failed to marshal JSON API request", err)
+		return err
+	}
+
+	r, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		log.Errorln("Failed to create request", err)
+		return err
+	}
+
+	r.Header.Set("Content-Type", "application/json")
+
+	return Do(client, r, header, out, statusError)
+}
+
+// PostForm sends form as url encoded body to url and decodes JSON response into out
+func PostForm(client *http.Client, url string, header http.Header, form url.Values, out interface{}, statusError StatusErrorFunc) error {
+	r, err := http.NewRequest("POST", url, strings.NewReader(form.Encode()))
+	if err != nil {
+		log.Errorln("Failed to create request", err)
+		return err
+	}
+
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return Do(client, r, header, out, statusError)
+}
+
+// Do sends request with extra headers and decodes JSON response into out. Failed connections are transient,
+// responses outside of 2xx go through statusError or StatusError when it's nil
+func Do(client *http.Client, r *http.Request, header http.Header, out interface{}, statusError StatusErrorFunc) error {
+	start := time.Now()
+
+	for name, values := range header {
+		r.Header.Del(name)
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+
+	resp, err := client.Do(r)
+	if err != nil {
+		log.Errorln("Failed to do request", err)
+		return TransientError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Errorln("Failed to read response body", err)
+		return TransientError{Err: err}
+	}
+
+	log.WithFields(log.Fields{
+		"time":   time.Since(start),
+		"status": resp.StatusCode,
+	}).Debugf("%s %s%s: %s", r.Method, r.URL.Host, r.URL.Path, contents)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if statusError == nil {
+			statusError = StatusError
+		}
+
+		return statusError(resp, contents)
+	}
+
+	if err := json.Unmarshal(contents, out); err != nil {
+		log.Errorln("Failed to unmarshal JSON API response", err)
+		return fmt.Errorf("unexpected response %q: %v", contents, err)
+	}
+
+	return nil
+}
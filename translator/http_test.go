@@ -0,0 +1,73 @@
+package translator
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestPostJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("X-Key") != "secret" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+
+		fmt.Fprint(w, `{"text": "Hello"}`)
+	}))
+	defer srv.Close()
+
+	var out struct {
+		Text string `json:"text"`
+	}
+
+	if err := PostJSON(srv.Client(), srv.URL, http.Header{"X-Key": {"secret"}}, map[string]string{"text": "こんにちは"}, &out, nil); err != nil || out.Text != "Hello" {
+		t.Errorf("PostJSON() = %+v, %v", out, err)
+	}
+}
+
+func TestPostForm(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		statusError StatusErrorFunc
+		want        interface{}
+	}{
+		{"plain status", http.StatusServiceUnavailable, nil, &TransientError{}},
+		{"api specific", http.StatusBadRequest, func(resp *http.Response, body []byte) error { return UnsupportedError{Message: string(body)} }, &UnsupportedError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.PostFormValue("text") != "こんにちは" {
+					t.Errorf("form = %v", r.PostForm)
+				}
+
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			var out interface{}
+
+			err := PostForm(srv.Client(), srv.URL, nil, url.Values{"text": {"こんにちは"}}, &out, tt.statusError)
+			if !errors.As(err, tt.want) {
+				t.Errorf("PostForm() error = %#v, want %T", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDo_Unreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	r, _ := http.NewRequest("GET", srv.URL, nil)
+
+	var out interface{}
+	if err := Do(srv.Client(), r, nil, &out, nil); !errors.As(err, &TransientError{}) {
+		t.Errorf("Do() error = %#v, want TransientError", err)
+	}
+}
@@ -0,0 +1,228 @@
+package libretranslate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+const (
+	defaultURL       = "http://127.0.0.1:5000"
+	defaultBatchSize = 20
+
+	timeout     = 30 * time.Second
+	batchWindow = 50 * time.Millisecond
+
+	// Server that couldn't be reached for language list is asked again after this, doubling up to max
+	minDiscoveryDelay = 10 * time.Second
+	maxDiscoveryDelay = 5 * time.Minute
+)
+
+type Translate struct {
+	enabled bool
+	client  *http.Client
+	batcher *translator.Batcher
+
+	apiURL string
+	apiKey string
+
+	// Target languages by source language, as reported by the server, nil until it answers
+	pairs     map[string]map[string]bool
+	pairsLock *sync.Mutex
+
+	discovering    bool
+	discoveryDelay time.Duration
+	nextDiscovery  time.Time
+}
+
+// https://libretranslate.com/docs
+type translateRequest struct {
+	Q      []string `json:"q"`
+	Source string   `json:"source"`
+	Target string   `json:"target"`
+	Format string   `json:"format"`
+	APIKey string   `json:"api_key,omitempty"`
+}
+
+type translateResponse struct {
+	TranslatedText []string `json:"translatedText"`
+}
+
+type language struct {
+	Code    string   `json:"code"`
+	Name    string   `json:"name"`
+	Targets []string `json:"targets"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// statusError maps api errors, message is in json body
+func statusError(from, to string) translator.StatusErrorFunc {
+	return func(resp *http.Response, body []byte) error {
+		var e apiError
+		if err := json.Unmarshal(body, &e); err == nil && len(e.Error) > 0 {
+			body = []byte(e.Error)
+		}
+
+		// Server refuses pairs it doesn't have models for
+		if resp.StatusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(e.Error), "not supported") {
+			return translator.UnsupportedError{From: from, To: to, Message: e.Error}
+		}
+
+		return translator.StatusError(resp, body)
+	}
+}
+
+func New() *Translate {
+	return &Translate{
+		client:         &http.Client{Timeout: timeout},
+		pairsLock:      &sync.Mutex{},
+		discoveryDelay: minDiscoveryDelay,
+	}
+}
+
+func (t *Translate) Name() string {
+	return "LibreTranslate"
+}
+
+func (t *Translate) Start(c config.TranslatorConfig) error {
+	t.apiURL = strings.TrimSuffix(c.URL, "/")
+	if len(t.apiURL) < 1 {
+		t.apiURL = defaultURL
+	}
+
+	t.apiKey = c.Key
+
+	// Server may start after us, language list is asked for again on later requests then
+	t.knownPairs()
+
+	batchSize := c.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	t.batcher = translator.NewBatcher(t.translateBatch, batchWindow, batchSize, 0)
+
+	t.enabled = true
+
+	return nil
+}
+
+func (t *Translate) Enabled() bool {
+	return t.enabled
+}
+
+// Supports reports whether server has models for language pair
+func (t *Translate) Supports(from, to string) bool {
+	return t.knownPairs()[from][to]
+}
+
+// knownPairs returns pairs reported by server, asking it again with backoff while it can't be reached
+func (t *Translate) knownPairs() map[string]map[string]bool {
+	t.pairsLock.Lock()
+	if t.pairs != nil || t.discovering || time.Now().Before(t.nextDiscovery) {
+		defer t.pairsLock.Unlock()
+		return t.pairs
+	}
+
+	t.discovering = true
+	t.pairsLock.Unlock()
+
+	pairs, err := t.languages()
+
+	t.pairsLock.Lock()
+	defer t.pairsLock.Unlock()
+
+	t.discovering = false
+
+	if err != nil {
+		log.Warnf("%s: Failed to get supported languages from %s, retrying in %s: %v", t.Name(), t.apiURL, t.discoveryDelay, err)
+
+		t.nextDiscovery = time.Now().Add(t.discoveryDelay)
+		t.discoveryDelay *= 2
+		if t.discoveryDelay > maxDiscoveryDelay {
+			t.discoveryDelay = maxDiscoveryDelay
+		}
+
+		return nil
+	}
+
+	log.Infof("%s: Supports %d source languages", t.Name(), len(pairs))
+
+	t.pairs = pairs
+
+	return pairs
+}
+
+// languages asks server which pairs it can translate, older servers don't list targets so every language is assumed to work with every other
+func (t *Translate) languages() (map[string]map[string]bool, error) {
+	r, err := http.NewRequest("GET", t.apiURL+"/languages", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var languages []language
+	if err := translator.Do(t.client, r, nil, &languages, nil); err != nil {
+		return nil, err
+	}
+
+	pairs := make(map[string]map[string]bool)
+
+	for _, from := range languages {
+		targets := from.Targets
+		if targets == nil {
+			for _, to := range languages {
+				targets = append(targets, to.Code)
+			}
+		}
+
+		pairs[from.Code] = make(map[string]bool)
+		for _, to := range targets {
+			if to != from.Code {
+				pairs[from.Code][to] = true
+			}
+		}
+	}
+
+	return pairs, nil
+}
+
+func (t *Translate) Translate(req *translator.Request) (string, error) {
+	pairs := t.knownPairs()
+	if pairs == nil {
+		return "", translator.TransientError{Err: fmt.Errorf("language list isn't known yet, server couldn't be reached")}
+	}
+
+	if !pairs[req.From][req.To] {
+		return "", translator.UnsupportedError{From: req.From, To: req.To, Message: "not in server language list"}
+	}
+
+	return t.batcher.Translate(req)
+}
+
+func (t *Translate) translateBatch(from, to string, texts []string) ([]string, error) {
+	req := translateRequest{
+		Q:      texts,
+		Source: from,
+		Target: to,
+		Format: "text",
+		APIKey: t.apiKey,
+	}
+
+	var response translateResponse
+	if err := translator.PostJSON(t.client, t.apiURL+"/translate", nil, req, &response, statusError(from, to)); err != nil {
+		return nil, err
+	}
+
+	return response.TranslatedText, nil
+}
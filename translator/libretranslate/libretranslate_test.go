@@ -0,0 +1,175 @@
+package libretranslate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+	"gitgud.io/softashell/comfy-translator/translator/translatortest"
+)
+
+const languagesJSON = `[
+	{"code": "en", "name": "English", "targets": ["ja", "ru"]},
+	{"code": "ja", "name": "Japanese", "targets": ["en"]},
+	{"code": "ru", "name": "Russian", "targets": ["en"]}
+]`
+
+// fakeServer answers like LibreTranslate, translations are input with target language prepended
+func fakeServer(t *testing.T, languages string, requests *[]translateRequest, lock *sync.Mutex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/languages":
+			fmt.Fprint(w, languages)
+		case "/translate":
+			var req translateRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Error(err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			lock.Lock()
+			*requests = append(*requests, req)
+			lock.Unlock()
+
+			if req.APIKey != "secret" {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"error": "Invalid API key"}`)
+				return
+			}
+
+			var resp translateResponse
+			for _, q := range req.Q {
+				resp.TranslatedText = append(resp.TranslatedText, req.Target+" "+q)
+			}
+
+			json.NewEncoder(w).Encode(resp)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func TestTranslate_Translate(t *testing.T) {
+	var lock sync.Mutex
+	var requests []translateRequest
+
+	tr := New()
+	translatortest.Start(t, tr, config.TranslatorConfig{URL: "/", Key: "secret"}, fakeServer(t, languagesJSON, &requests, &lock))
+
+	translatortest.Batched(t, tr, []string{"一", "二", "三"}, func(text string) string { return "en " + text }, func() int {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return len(requests)
+	})
+
+	lock.Lock()
+	for _, req := range requests {
+		if req.Source != "ja" || req.Format != "text" {
+			t.Errorf("unexpected request %+v", req)
+		}
+	}
+	lock.Unlock()
+
+	// Pair server doesn't list isn't sent at all
+	if _, err := tr.Translate(&translator.Request{Text: "一", From: "ja", To: "ru"}); !errors.As(err, &translator.UnsupportedError{}) {
+		t.Errorf("Translate() to ru error = %v, want UnsupportedError", err)
+	}
+}
+
+func TestTranslate_Supports(t *testing.T) {
+	tests := []struct {
+		name      string
+		languages string
+		from, to  string
+		want      bool
+	}{
+		{"listed", languagesJSON, "en", "ru", true},
+		{"not listed", languagesJSON, "ja", "ru", false},
+		{"unknown source", languagesJSON, "ko", "en", false},
+		{"same language", languagesJSON, "en", "en", false},
+		// Older servers don't have targets, anything goes
+		{"no targets", `[{"code": "en", "name": "English"}, {"code": "ja", "name": "Japanese"}]`, "ja", "en", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lock sync.Mutex
+			var requests []translateRequest
+
+			tr := New()
+			translatortest.Start(t, tr, config.TranslatorConfig{}, fakeServer(t, tt.languages, &requests, &lock))
+
+			if got := tr.Supports(tt.from, tt.to); got != tt.want {
+				t.Errorf("Supports(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslate_Errors(t *testing.T) {
+	var lock sync.Mutex
+	var requests []translateRequest
+
+	tr := New()
+	translatortest.Start(t, tr, config.TranslatorConfig{Key: "wrong"}, fakeServer(t, languagesJSON, &requests, &lock))
+
+	if _, err := tr.Translate(&translator.Request{Text: "一", From: "ja", To: "en"}); !errors.As(err, &translator.AuthFailedError{}) {
+		t.Errorf("Translate() error = %v, want AuthFailedError", err)
+	}
+
+}
+
+func TestTranslate_LateServer(t *testing.T) {
+	var lock sync.Mutex
+	var requests []translateRequest
+	var up bool
+
+	languages := fakeServer(t, languagesJSON, &requests, &lock)
+	srv := translatortest.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		started := up
+		lock.Unlock()
+
+		if !started {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+
+		languages(w, r)
+	}))
+
+	// Engine starts even when server isn't up yet
+	tr := New()
+	if err := tr.Start(config.TranslatorConfig{URL: srv.URL, Key: "secret"}); err != nil || !tr.Enabled() {
+		t.Fatalf("Start() error = %v, enabled = %v", err, tr.Enabled())
+	}
+
+	if _, err := tr.Translate(&translator.Request{Text: "一", From: "ja", To: "en"}); !errors.As(err, &translator.TransientError{}) {
+		t.Errorf("Translate() error = %v, want TransientError", err)
+	}
+
+	lock.Lock()
+	up = true
+	lock.Unlock()
+
+	// Not asked again before backoff runs out
+	if tr.Supports("ja", "en") {
+		t.Error("Supports() asked server again before backoff")
+	}
+
+	tr.pairsLock.Lock()
+	tr.nextDiscovery = time.Time{}
+	tr.pairsLock.Unlock()
+
+	if out, err := tr.Translate(&translator.Request{Text: "一", From: "ja", To: "en"}); err != nil || out != "en 一" {
+		t.Errorf("Translate() = %q, %v", out, err)
+	}
+}
@@ -0,0 +1,128 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+var (
+	// Reasoning models think out loud before answering
+	thinkRegex = regexp.MustCompile(`(?s)<think>.*?</think>`)
+
+	// Answers starting like this are talking to us instead of translating, kept specific
+	// enough that lines starting with "Sure" or "I cannot" still get through
+	chattyPrefixes = []string{
+		"here is the translation", "here's the translation", "here is a translation", "here's a translation",
+		"the translation is", "this translates", "i'm sorry, but", "i cannot translate", "i can't translate", "as an ai",
+	}
+
+	// Explanations of what the line says, "This means war!" or "This text is for you." are translations though
+	explainRegex = regexp.MustCompile(`^this (sentence|text|phrase|line) (means|says|translates|reads|is saying)\b|^this means:? *["'“「]`)
+
+	// Labels and explanations around the translation, ones with punctuation are only
+	// commentary when source doesn't have it as well, "メモ：明日" is fine as "Note: tomorrow"
+	chattyMarkers = []string{
+		"translates to", "translated as", "translator's note", "literal translation",
+		"(note", "(literally", "note:",
+	}
+
+	// Label has to start a line, "Bad translation: ..." could be what the line says
+	labelRegex = regexp.MustCompile(`(?m)^\s*translation:`)
+
+	quotePairs = [][2]string{{`"`, `"`}, {"「", "」"}, {"『", "』"}, {"“", "”"}}
+)
+
+// Languages where leftover japanese characters don't mean the text wasn't translated
+var hanTargets = map[string]bool{"ja": true, "zh": true, "ko": true}
+
+// Answer may be this many times longer than input before it's considered rambling,
+// english tends to take about three times more characters than japanese
+const (
+	maxLengthRatio = 6
+	lengthSlack    = 40
+)
+
+func (t *Translate) PostProcessVersion() int {
+	return postProcessVersion
+}
+
+// PostProcess takes the translation out of model's answer and rejects answers that have anything else in them
+func (t *Translate) PostProcess(req *translator.Request, raw string) (string, error) {
+	bad := translator.BadTranslationError{Input: req.Text, Output: raw}
+
+	out := strings.TrimSpace(thinkRegex.ReplaceAllString(raw, ""))
+	out = unquote(out, strings.TrimSpace(req.Text))
+
+	if len(out) < 1 {
+		return "", bad
+	}
+
+	// Extra lines are almost always explanations
+	if strings.Count(out, "\n") > strings.Count(strings.TrimSpace(req.Text), "\n") {
+		return "", bad
+	}
+
+	lower := strings.ToLower(out)
+
+	for _, prefix := range chattyPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return "", bad
+		}
+	}
+
+	if explainRegex.MatchString(lower) {
+		return "", bad
+	}
+
+	for _, marker := range chattyMarkers {
+		if strings.Contains(lower, marker) && !inSource(marker, req.Text) {
+			return "", bad
+		}
+	}
+
+	if labelRegex.MatchString(lower) && !inSource(":", req.Text) {
+		return "", bad
+	}
+
+	if utf8.RuneCountInString(out) > maxLengthRatio*utf8.RuneCountInString(req.Text)+lengthSlack {
+		return "", bad
+	}
+
+	if !hanTargets[strings.ToLower(req.To)] && translator.IsTranslationGarbage(out) {
+		return "", bad
+	}
+
+	return out, nil
+}
+
+// inSource reports whether punctuation of marker is in source text too, then it's more likely translated than added by model
+func inSource(marker, text string) bool {
+	switch {
+	case strings.Contains(marker, ":"):
+		return strings.ContainsAny(text, ":：")
+	case strings.HasPrefix(marker, "("):
+		return strings.ContainsAny(text, "(（")
+	}
+
+	return false
+}
+
+// unquote removes quotes model wrapped the answer in when original text wasn't quoted
+func unquote(out, text string) string {
+	for _, q := range quotePairs {
+		if strings.HasPrefix(text, q[0]) {
+			return out
+		}
+	}
+
+	for _, q := range quotePairs {
+		if strings.HasPrefix(out, q[0]) && strings.HasSuffix(out, q[1]) && len(out) > len(q[0])+len(q[1]) {
+			return strings.TrimSpace(out[len(q[0]) : len(out)-len(q[1])])
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,223 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+const postProcessVersion = 2
+
+const (
+	defaultURL = "http://127.0.0.1:8080/v1"
+
+	// Local models on modest hardware can take a while
+	timeout = 2 * time.Minute
+
+	// How long examples taken from cache are used before they are loaded again
+	examplesTTL = 10 * time.Minute
+)
+
+type Translate struct {
+	enabled bool
+	client  *http.Client
+
+	apiURL      string
+	apiKey      string
+	model       string
+	prompt      *template.Template
+	temperature float64
+	maxTokens   int
+	glossary    []Term
+	contextSize int
+
+	examples       translator.ExampleSource
+	exampleService string
+	exampleCount   int
+	exampleCache   map[string]cachedExamples
+	exampleLock    *sync.Mutex
+}
+
+type cachedExamples struct {
+	examples []translator.Example
+	loaded   time.Time
+}
+
+// https://platform.openai.com/docs/api-reference/chat/create
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+type chatError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
+// statusError maps api errors, servers other than OpenAI mostly stick to plain status codes
+func statusError(resp *http.Response, body []byte) error {
+	var e chatError
+	if err := json.Unmarshal(body, &e); err == nil && e.Error.Code == "insufficient_quota" {
+		return translator.BlockedError{Message: "quota exceeded: " + e.Error.Message}
+	}
+
+	return translator.StatusError(resp, body)
+}
+
+func New() *Translate {
+	return &Translate{
+		client:      &http.Client{Timeout: timeout},
+		exampleLock: &sync.Mutex{},
+	}
+}
+
+func (t *Translate) Name() string {
+	return "LLM"
+}
+
+func (t *Translate) Start(c config.TranslatorConfig) error {
+	t.apiURL = strings.TrimSuffix(c.URL, "/")
+	if len(t.apiURL) < 1 {
+		t.apiURL = defaultURL
+	}
+
+	t.apiKey = c.Key
+	t.model = c.Model
+	t.temperature = c.Temperature
+	t.maxTokens = c.MaxTokens
+	t.glossary = newGlossary(c.Glossary)
+	t.contextSize = c.Context
+
+	prompt := c.Prompt
+	if len(strings.TrimSpace(prompt)) < 1 {
+		prompt = defaultPrompt
+	}
+
+	var err error
+
+	t.prompt, err = template.New("prompt").Parse(prompt)
+	if err != nil {
+		return fmt.Errorf("%s: Invalid prompt template: %v", t.Name(), err)
+	}
+
+	t.exampleCount = c.Examples
+	t.exampleService = c.ExampleService
+	if len(t.exampleService) < 1 {
+		t.exampleService = t.Name()
+	}
+	t.exampleCache = make(map[string]cachedExamples)
+
+	t.enabled = true
+
+	return nil
+}
+
+func (t *Translate) Enabled() bool {
+	return t.enabled
+}
+
+// UseExamples sets where previous translations shown as examples come from
+func (t *Translate) UseExamples(source translator.ExampleSource) {
+	t.examples = source
+}
+
+// ContextSize returns how many previous lines of conversation are sent along
+func (t *Translate) ContextSize() int {
+	return t.contextSize
+}
+
+func (t *Translate) Translate(req *translator.Request) (string, error) {
+	return t.TranslateContext(req, nil)
+}
+
+// TranslateContext translates text with previous lines of conversation sent as earlier turns
+func (t *Translate) TranslateContext(req *translator.Request, context []translator.Line) (string, error) {
+	messages, err := t.messages(req, context)
+	if err != nil {
+		return "", err
+	}
+
+	chat := chatRequest{
+		Model:       t.model,
+		Messages:    messages,
+		Temperature: t.temperature,
+		MaxTokens:   t.maxTokens,
+	}
+
+	header := http.Header{}
+	if len(t.apiKey) > 0 {
+		header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	var response chatResponse
+	if err := translator.PostJSON(t.client, t.apiURL+"/chat/completions", header, chat, &response, statusError); err != nil {
+		return "", err
+	}
+
+	if len(response.Choices) < 1 {
+		return "", fmt.Errorf("Empty response")
+	}
+
+	choice := response.Choices[0]
+
+	// Ran out of tokens before finishing, most likely started rambling
+	if choice.FinishReason == "length" {
+		return "", translator.BadTranslationError{Input: req.Text, Output: choice.Message.Content}
+	}
+
+	return choice.Message.Content, nil
+}
+
+// cachedExamplesFor returns examples for language pair, they are loaded from cache once in a while
+func (t *Translate) cachedExamplesFor(from, to string) []translator.Example {
+	if t.examples == nil || t.exampleCount <= 0 {
+		return nil
+	}
+
+	t.exampleLock.Lock()
+	defer t.exampleLock.Unlock()
+
+	pair := from + "-" + to
+
+	cached, ok := t.exampleCache[pair]
+	if ok && time.Since(cached.loaded) < examplesTTL {
+		return cached.examples
+	}
+
+	examples, err := t.examples(t.exampleService, from, to, t.exampleCount)
+	if err != nil {
+		// Keep using old ones and don't try again until they would expire
+		log.Warnf("%s: Failed to load examples: %v", t.Name(), err)
+		examples = cached.examples
+	}
+
+	t.exampleCache[pair] = cachedExamples{examples: examples, loaded: time.Now()}
+
+	return examples
+}
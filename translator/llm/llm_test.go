@@ -0,0 +1,236 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+	"gitgud.io/softashell/comfy-translator/translator/translatortest"
+)
+
+func newTestTranslate(t *testing.T, conf config.TranslatorConfig, h http.HandlerFunc) *Translate {
+	conf.URL = "/v1/"
+
+	tr := New()
+	translatortest.Start(t, tr, conf, h)
+
+	return tr
+}
+
+func TestTranslate_Translate(t *testing.T) {
+	var got chatRequest
+
+	tr := newTestTranslate(t, config.TranslatorConfig{
+		Key:         "secret",
+		Model:       "test-model",
+		Temperature: 0.2,
+		MaxTokens:   100,
+		Examples:    2,
+		Glossary:    map[string]string{"先輩": "senpai", "先生": "sensei"},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "Good morning, senpai."}, "finish_reason": "stop"}]}`)
+	})
+
+	var exampleService string
+
+	tr.UseExamples(func(service, from, to string, limit int) ([]translator.Example, error) {
+		exampleService = service
+
+		return []translator.Example{
+			{Text: "先輩、おはよう", Translation: "Morning, senpai."},
+			{Text: "はい", Translation: "Yes."},
+		}, nil
+	})
+
+	out, err := tr.Translate(&translator.Request{Text: "先輩、おはようございます", From: "ja", To: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out != "Good morning, senpai." {
+		t.Errorf("Translate() = %q", out)
+	}
+
+	if exampleService != "LLM" {
+		t.Errorf("examples taken from %q", exampleService)
+	}
+
+	if got.Model != "test-model" || got.Temperature != 0.2 || got.MaxTokens != 100 {
+		t.Errorf("unexpected request %+v", got)
+	}
+
+	var roles []string
+	for _, m := range got.Messages {
+		roles = append(roles, m.Role)
+	}
+
+	if want := []string{"system", "user", "assistant", "user", "assistant", "user"}; !reflect.DeepEqual(roles, want) {
+		t.Fatalf("roles = %v, want %v", roles, want)
+	}
+
+	system := got.Messages[0].Content
+	if !strings.Contains(system, "from Japanese to English") || !strings.Contains(system, "先輩 = senpai") {
+		t.Errorf("system prompt is missing language or glossary:\n%s", system)
+	}
+
+	if strings.Contains(system, "先生") {
+		t.Errorf("system prompt has glossary term that isn't in the text:\n%s", system)
+	}
+
+	if got.Messages[5].Content != "先輩、おはようございます" {
+		t.Errorf("last message = %q", got.Messages[5].Content)
+	}
+}
+
+func TestTranslate_TranslateContext(t *testing.T) {
+	var got chatRequest
+
+	tr := newTestTranslate(t, config.TranslatorConfig{Context: 5}, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+
+		fmt.Fprint(w, `{"choices": [{"message": {"content": "She went home."}, "finish_reason": "stop"}]}`)
+	})
+
+	if tr.ContextSize() != 5 {
+		t.Errorf("ContextSize() = %d", tr.ContextSize())
+	}
+
+	context := []translator.Line{
+		{Text: "花子はどこ？", Translation: "Where's Hanako?"},
+	}
+
+	if _, err := tr.TranslateContext(&translator.Request{Text: "家に帰った", From: "ja", To: "en"}, context); err != nil {
+		t.Fatal(err)
+	}
+
+	var contents []string
+	for _, m := range got.Messages[1:] {
+		contents = append(contents, m.Role+": "+m.Content)
+	}
+
+	want := []string{"user: 花子はどこ？", "assistant: Where's Hanako?", "user: 家に帰った"}
+	if !reflect.DeepEqual(contents, want) {
+		t.Errorf("messages = %v, want %v", contents, want)
+	}
+}
+
+func TestTranslate_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   interface{}
+	}{
+		{"quota", http.StatusTooManyRequests, `{"error": {"message": "You exceeded your current quota", "type": "insufficient_quota", "code": "insufficient_quota"}}`, &translator.BlockedError{}},
+		{"rate limited", http.StatusTooManyRequests, `{"error": {"message": "Rate limit reached", "code": "rate_limit_exceeded"}}`, &translator.RateLimitedError{}},
+		{"bad key", http.StatusUnauthorized, `{"error": {"message": "Incorrect API key provided"}}`, &translator.AuthFailedError{}},
+		{"server loading model", http.StatusServiceUnavailable, `{"error": {"message": "Loading model"}}`, &translator.TransientError{}},
+		{"out of tokens", http.StatusOK, `{"choices": [{"message": {"content": "Good morning, and"}, "finish_reason": "length"}]}`, &translator.BadTranslationError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestTranslate(t, config.TranslatorConfig{}, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+
+			_, err := tr.Translate(&translator.Request{Text: "おはよう", From: "ja", To: "en"})
+			if !errors.As(err, tt.want) {
+				t.Errorf("Translate() error = %#v, want %T", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslate_Start(t *testing.T) {
+	tr := New()
+
+	if err := tr.Start(config.TranslatorConfig{Prompt: "{{.From"}); err == nil {
+		t.Error("expected error for broken prompt template")
+	}
+
+	if err := tr.Start(config.TranslatorConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.apiURL != defaultURL {
+		t.Errorf("apiURL = %q, want default", tr.apiURL)
+	}
+}
+
+func TestTranslate_PostProcess(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		to      string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{"plain", "おはよう", "en", "Good morning.", "Good morning.", false},
+		{"whitespace", "おはよう", "en", "\n Good morning.\n", "Good morning.", false},
+		{"reasoning", "おはよう", "en", "<think>\nUser greets.\n</think>\n\nGood morning.", "Good morning.", false},
+		{"quoted answer", "おはよう", "en", `"Good morning."`, "Good morning.", false},
+		{"quoted original", "「おはよう」", "en", `"Good morning."`, `"Good morning."`, false},
+		{"starts like chat but isn't", "いいよ", "en", "Sure, why not.", "Sure, why not.", false},
+		{"multiple lines", "おはよう\nげんき？", "en", "Good morning.\nHow are you?", "Good morning.\nHow are you?", false},
+		{"empty", "おはよう", "en", "  ", "", true},
+		{"label", "おはよう", "en", "Translation: Good morning.", "", true},
+		{"preamble", "おはよう", "en", "Here's the translation of your text: Good morning.", "", true},
+		{"explanation on next line", "おはよう", "en", "Good morning.\n\nThis is a casual greeting.", "", true},
+		{"note", "おはよう", "en", "Good morning. (Note: casual greeting)", "", true},
+		{"refusal", "おはよう", "en", "I'm sorry, but I can't help with that.", "", true},
+		{"explanation", "おはよう", "en", `This means "Good morning".`, "", true},
+		{"sentence explanation", "おはよう", "en", "This sentence says good morning.", "", true},
+		{"label on second line", "おはよう\nげんき？", "en", "Good morning.\nTranslation: How are you?", "", true},
+		{"starts with this means", "これは戦争だ！", "en", "This means war!", "This means war!", false},
+		{"starts with this text", "この手紙はあなたに", "en", "This text is for you.", "This text is for you.", false},
+		{"translated note", "メモ：明日", "en", "Note: tomorrow", "Note: tomorrow", false},
+		{"translated label", "翻訳：田中", "en", "Translation: Tanaka", "Translation: Tanaka", false},
+		{"translation in sentence", "ひどい翻訳だ", "en", "What a bad translation: awful.", "What a bad translation: awful.", false},
+		{"translated parenthesis", "（メモ）明日", "en", "(Note) tomorrow", "(Note) tomorrow", false},
+		{"rambling", "はい", "en", strings.Repeat("Yes, that is right. ", 10), "", true},
+		{"untranslated", "おはようございます", "en", "おはようございます", "", true},
+		{"chinese target", "おはようございます", "zh", "早上好", "早上好", false},
+	}
+
+	tr := New()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tr.PostProcess(&translator.Request{Text: tt.text, From: "ja", To: tt.to}, tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PostProcess() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil && !errors.As(err, &translator.BadTranslationError{}) {
+				t.Errorf("PostProcess() error = %#v, want BadTranslationError", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("PostProcess() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+const defaultPrompt = `You are a professional translator of visual novels and games.
+Translate the text from {{.From}} to {{.To}}. Keep the tone, honorifics and line breaks of the original.
+Reply with the translation only, without quotes, notes or explanations.
+{{- if .Glossary}}
+
+Always translate these names and terms like this:
+{{- range .Glossary}}
+{{.Source}} = {{.Target}}
+{{- end}}
+{{- end}}`
+
+// Names models understand better than language codes
+var languageNames = map[string]string{
+	"ja": "Japanese",
+	"en": "English",
+	"zh": "Chinese",
+	"ko": "Korean",
+	"ru": "Russian",
+	"de": "German",
+	"fr": "French",
+	"es": "Spanish",
+	"pt": "Portuguese",
+	"it": "Italian",
+}
+
+// Term is a glossary entry
+type Term struct {
+	Source string
+	Target string
+}
+
+// promptData is available to prompt template
+type promptData struct {
+	From     string // Language names
+	To       string
+	FromCode string
+	ToCode   string
+	Glossary []Term // Only terms found in translated text
+}
+
+// newGlossary sorts terms so longer ones come first and prompt stays the same between requests
+func newGlossary(terms map[string]string) []Term {
+	var out []Term
+	for source, target := range terms {
+		out = append(out, Term{Source: source, Target: target})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i].Source) != len(out[j].Source) {
+			return len(out[i].Source) > len(out[j].Source)
+		}
+
+		return out[i].Source < out[j].Source
+	})
+
+	return out
+}
+
+func languageName(code string) string {
+	if name, ok := languageNames[strings.ToLower(code)]; ok {
+		return name
+	}
+
+	return code
+}
+
+// messages builds the conversation sent to model: system prompt, examples and previous lines as earlier turns and the text itself
+func (t *Translate) messages(req *translator.Request, context []translator.Line) ([]chatMessage, error) {
+	data := promptData{
+		From:     languageName(req.From),
+		To:       languageName(req.To),
+		FromCode: req.From,
+		ToCode:   req.To,
+	}
+
+	// Whole glossary would waste context on every request
+	for _, term := range t.glossary {
+		if strings.Contains(req.Text, term.Source) {
+			data.Glossary = append(data.Glossary, term)
+		}
+	}
+
+	var prompt bytes.Buffer
+	if err := t.prompt.Execute(&prompt, data); err != nil {
+		return nil, err
+	}
+
+	messages := []chatMessage{{Role: "system", Content: prompt.String()}}
+
+	for _, e := range t.cachedExamplesFor(req.From, req.To) {
+		if e.Text == req.Text {
+			continue
+		}
+
+		messages = append(messages,
+			chatMessage{Role: "user", Content: e.Text},
+			chatMessage{Role: "assistant", Content: e.Translation},
+		)
+	}
+
+	// Previous lines go last so they read as the conversation leading up to the text
+	for _, line := range context {
+		messages = append(messages,
+			chatMessage{Role: "user", Content: line.Text},
+			chatMessage{Role: "assistant", Content: line.Translation},
+		)
+	}
+
+	messages = append(messages, chatMessage{Role: "user", Content: req.Text})
+
+	return messages, nil
+}
@@ -0,0 +1,112 @@
+package sugoi
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+const postProcessVersion = 1
+
+const (
+	defaultURL       = "http://127.0.0.1:14366/"
+	defaultBatchSize = 16
+
+	// Translating on CPU takes a while for bigger batches
+	timeout = time.Minute
+
+	batchWindow = 50 * time.Millisecond
+)
+
+// Words model had no translation for
+var unknownRegex = regexp.MustCompile(` *<unk> *`)
+
+type Translate struct {
+	enabled bool
+	client  *http.Client
+	batcher *translator.Batcher
+
+	apiURL string
+}
+
+// Server answers with an array of translations in the same order
+type sugoiRequest struct {
+	Content []string `json:"content"`
+	Message string   `json:"message"`
+}
+
+func New() *Translate {
+	return &Translate{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (t *Translate) Name() string {
+	return "Sugoi"
+}
+
+func (t *Translate) Start(c config.TranslatorConfig) error {
+	t.apiURL = c.URL
+	if len(t.apiURL) < 1 {
+		t.apiURL = defaultURL
+	}
+
+	batchSize := c.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	t.batcher = translator.NewBatcher(t.translateBatch, batchWindow, batchSize, 0)
+
+	t.enabled = true
+
+	return nil
+}
+
+func (t *Translate) Enabled() bool {
+	return t.enabled
+}
+
+// Supports reports whether language pair can be translated, models only come in one direction
+func (t *Translate) Supports(from, to string) bool {
+	return from == "ja" && to == "en"
+}
+
+func (t *Translate) Translate(req *translator.Request) (string, error) {
+	if !t.Supports(req.From, req.To) {
+		return "", translator.UnsupportedError{From: req.From, To: req.To, Message: "only translates from ja to en"}
+	}
+
+	return t.batcher.Translate(req)
+}
+
+func (t *Translate) translateBatch(from, to string, texts []string) ([]string, error) {
+	var out []string
+	if err := translator.PostJSON(t.client, t.apiURL, nil, sugoiRequest{Content: texts, Message: "translate sentences"}, &out, nil); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (t *Translate) PostProcessVersion() int {
+	return postProcessVersion
+}
+
+// PostProcess removes tokens model couldn't translate and rejects output that wasn't translated at all
+func (t *Translate) PostProcess(req *translator.Request, out string) (string, error) {
+	cleaned := strings.TrimSpace(unknownRegex.ReplaceAllString(out, " "))
+
+	if len(cleaned) < 1 || translator.IsTranslationGarbage(cleaned) {
+		return "", translator.BadTranslationError{
+			Input:  req.Text,
+			Output: out,
+		}
+	}
+
+	return cleaned, nil
+}
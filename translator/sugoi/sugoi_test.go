@@ -0,0 +1,108 @@
+package sugoi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+	"gitgud.io/softashell/comfy-translator/translator/translatortest"
+)
+
+func newTestTranslate(t *testing.T, batchSize int, h http.HandlerFunc) *Translate {
+	tr := New()
+	translatortest.Start(t, tr, config.TranslatorConfig{BatchSize: batchSize}, h)
+
+	return tr
+}
+
+func TestTranslate_Translate(t *testing.T) {
+	var lock sync.Mutex
+	var batches [][]string
+
+	tr := newTestTranslate(t, 2, func(w http.ResponseWriter, r *http.Request) {
+		var req sugoiRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.Message != "translate sentences" {
+			t.Errorf("message = %q", req.Message)
+		}
+
+		lock.Lock()
+		batches = append(batches, req.Content)
+		lock.Unlock()
+
+		out := make([]string, len(req.Content))
+		for i, text := range req.Content {
+			out[i] = "en " + text
+		}
+
+		json.NewEncoder(w).Encode(out)
+	})
+
+	translatortest.Batched(t, tr, []string{"一", "二", "三", "四", "五"}, func(text string) string { return "en " + text }, func() int {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return len(batches)
+	})
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, b := range batches {
+		if len(b) > 2 {
+			t.Errorf("batch %v is bigger than configured size", b)
+		}
+	}
+}
+
+func TestTranslate_Errors(t *testing.T) {
+	tr := newTestTranslate(t, 0, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	if _, err := tr.Translate(&translator.Request{Text: "テスト", From: "ja", To: "ru"}); !errors.As(err, &translator.UnsupportedError{}) {
+		t.Errorf("Translate() to ru error = %v, want UnsupportedError", err)
+	}
+
+	if _, err := tr.Translate(&translator.Request{Text: "テスト", From: "ja", To: "en"}); !errors.As(err, &translator.TransientError{}) {
+		t.Errorf("Translate() error = %v, want TransientError", err)
+	}
+}
+
+func TestTranslate_PostProcess(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{"plain", "Good morning.", "Good morning.", false},
+		{"unknown word", "Good <unk> morning. <unk>", "Good morning.", false},
+		{"only unknown", "<unk>", "", true},
+		{"untranslated", "おはようございます", "", true},
+	}
+
+	tr := New()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tr.PostProcess(&translator.Request{Text: "おはよう", From: "ja", To: "en"}, tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PostProcess() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("PostProcess() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
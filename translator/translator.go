@@ -1,7 +1,6 @@
 package translator
 
 import (
-	"fmt"
 	"time"
 
 	"gitgud.io/softashell/comfy-translator/config"
@@ -12,6 +11,9 @@ type Request struct {
 	Text string `json:"text"`
 	From string `json:"from"`
 	To   string `json:"to"`
+
+	// Lines sent with the same session are treated as one conversation, empty when caller doesn't track it
+	Session string `json:"session,omitempty"`
 }
 
 type Response struct {
@@ -28,6 +30,48 @@ type Translator interface {
 	Translate(*Request) (string, error)
 }
 
+// PairChecker is implemented by translators that know which language pairs they support,
+// they are skipped for other pairs instead of being tried
+type PairChecker interface {
+	Supports(from, to string) bool
+}
+
+// PostProcessor is implemented by translators that clean up engine output, Translate returns raw output then.
+// Cache keeps raw output so cleanup can be applied again after PostProcessVersion changes
+type PostProcessor interface {
+	// PostProcessVersion has to go up whenever PostProcess rules change, older cached entries are cleaned up again by reprocess command
+	PostProcessVersion() int
+	PostProcess(req *Request, raw string) (string, error)
+}
+
+// Example is a known good translation shown to engines so they know what is expected from them
+type Example struct {
+	Text        string
+	Translation string
+}
+
+// ExampleSource returns up to limit good translations of service between given languages, best ones first
+type ExampleSource func(service, from, to string, limit int) ([]Example, error)
+
+// ExampleUser is implemented by translators that learn from previous translations
+type ExampleUser interface {
+	UseExamples(ExampleSource)
+}
+
+// Line is an already translated line of the same conversation
+type Line struct {
+	Text        string `json:"text"`
+	Translation string `json:"translation"`
+}
+
+// ContextTranslator is implemented by translators that do better knowing what was said before,
+// TranslateContext is used instead of Translate when there are previous lines, oldest first
+type ContextTranslator interface {
+	// ContextSize returns how many previous lines translator wants to see, 0 turns it off
+	ContextSize() int
+	TranslateContext(req *Request, context []Line) (string, error)
+}
+
 func CheckThrottle(lastReq time.Time, delay time.Duration) {
 	timePassed := time.Since(lastReq)
 	if timePassed < delay {
@@ -36,12 +80,3 @@ func CheckThrottle(lastReq time.Time, delay time.Duration) {
 		time.Sleep(sleep)
 	}
 }
-
-type BadTranslationError struct {
-	Input  string
-	Output string
-}
-
-func (e BadTranslationError) Error() string {
-	return fmt.Sprintf("garbage translation: %q => %q", e.Input, e.Output)
-}
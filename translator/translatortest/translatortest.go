@@ -0,0 +1,64 @@
+// Package translatortest has helpers for testing translators against fake http services
+package translatortest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+// NewServer serves h until test is done
+func NewServer(t testing.TB, h http.Handler) *httptest.Server {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// Start starts tr with URL of conf pointing at a server running h, URL from conf is appended to server address
+func Start(t testing.TB, tr translator.Translator, conf config.TranslatorConfig, h http.Handler) {
+	srv := NewServer(t, h)
+
+	conf.URL = srv.URL + conf.URL
+
+	if err := tr.Start(conf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Batched translates texts from ja to en at the same time so batching translators can join them, checks that each
+// result is want(text) and that service got fewer requests than there were texts
+func Batched(t testing.TB, tr translator.Translator, texts []string, want func(text string) string, requests func() int) {
+	t.Helper()
+
+	out := make([]string, len(texts))
+
+	var wg sync.WaitGroup
+	for i := range texts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			var err error
+			out[i], err = tr.Translate(&translator.Request{Text: texts[i], From: "ja", To: "en"})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range texts {
+		if w := want(texts[i]); out[i] != w {
+			t.Errorf("Translate(%q) = %q, want %q", texts[i], out[i], w)
+		}
+	}
+
+	if n := requests(); n >= len(texts) {
+		t.Errorf("made %d requests for %d texts, expected them to be batched", n, len(texts))
+	}
+}
@@ -0,0 +1,118 @@
+package yandex
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+)
+
+const (
+	cloudURL = "https://translate.api.cloud.yandex.net/translate/v2/translate"
+
+	// IAM tokens start with this, anything else is treated as api key
+	iamTokenPrefix = "t1."
+
+	// https://cloud.yandex.com/docs/translate/concepts/limits
+	maxLength = 10000
+
+	batchWindow = 100 * time.Millisecond
+)
+
+// https://cloud.yandex.com/docs/translate/api-ref/Translation/translate
+type cloudRequest struct {
+	FolderID           string   `json:"folderId,omitempty"`
+	Texts              []string `json:"texts"`
+	SourceLanguageCode string   `json:"sourceLanguageCode"`
+	TargetLanguageCode string   `json:"targetLanguageCode"`
+	Format             string   `json:"format"`
+}
+
+type cloudResponse struct {
+	Translations []struct {
+		Text                 string `json:"text"`
+		DetectedLanguageCode string `json:"detectedLanguageCode"`
+	} `json:"translations"`
+}
+
+type cloudError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// cloudStatusError maps api errors, codes are gRPC status codes https://cloud.yandex.com/docs/api-design-guide/concepts/errors
+func cloudStatusError(from, to string) translator.StatusErrorFunc {
+	return func(resp *http.Response, body []byte) error {
+		var e cloudError
+		if err := json.Unmarshal(body, &e); err != nil {
+			return translator.StatusError(resp, body)
+		}
+
+		msg := fmt.Sprintf("%s - %s", resp.Status, e.Message)
+
+		switch e.Code {
+		case 3: // INVALID_ARGUMENT
+			if strings.Contains(strings.ToLower(e.Message), "language") {
+				return translator.UnsupportedError{From: from, To: to, Message: msg}
+			}
+		case 9: // FAILED_PRECONDITION, billing account isn't active
+			return translator.BlockedError{Message: msg}
+		}
+
+		return translator.StatusError(resp, body)
+	}
+}
+
+func (t *Translate) startCloud(c config.TranslatorConfig) error {
+	t.cloudURL = c.URL
+	if len(t.cloudURL) < 1 {
+		t.cloudURL = cloudURL
+	}
+
+	// Api keys of service accounts use folder of the account, user accounts have to set it
+	t.folderID = c.FolderID
+
+	t.batcher = translator.NewBatcher(t.translateBatch, batchWindow, 0, maxLength)
+
+	t.cloud = true
+	t.enabled = true
+
+	return nil
+}
+
+// authorization returns header value for key, IAM tokens only last for 12 hours so api keys are better for long running servers
+func (t *Translate) authorization() string {
+	if strings.HasPrefix(t.apiKey, iamTokenPrefix) {
+		return "Bearer " + t.apiKey
+	}
+
+	return "Api-Key " + t.apiKey
+}
+
+func (t *Translate) translateBatch(from, to string, texts []string) ([]string, error) {
+	req := cloudRequest{
+		FolderID:           t.folderID,
+		Texts:              texts,
+		SourceLanguageCode: from,
+		TargetLanguageCode: to,
+		Format:             "PLAIN_TEXT",
+	}
+
+	header := http.Header{"Authorization": {t.authorization()}}
+
+	var response cloudResponse
+	if err := translator.PostJSON(t.client, t.cloudURL, header, req, &response, cloudStatusError(from, to)); err != nil {
+		return nil, err
+	}
+
+	out := make([]string, len(response.Translations))
+	for i := range response.Translations {
+		out[i] = response.Translations[i].Text
+	}
+
+	return out, nil
+}
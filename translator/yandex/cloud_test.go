@@ -0,0 +1,136 @@
+package yandex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"gitgud.io/softashell/comfy-translator/config"
+	"gitgud.io/softashell/comfy-translator/translator"
+	"gitgud.io/softashell/comfy-translator/translator/translatortest"
+)
+
+func newTestTranslate(t *testing.T, key string, h http.HandlerFunc) *Translate {
+	tr := New()
+	translatortest.Start(t, tr, config.TranslatorConfig{Key: key, FolderID: "b1gfolder"}, h)
+
+	return tr
+}
+
+func TestTranslate_Cloud(t *testing.T) {
+	var lock sync.Mutex
+	var batches int
+
+	tr := newTestTranslate(t, "AQVNsecret", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Api-Key AQVNsecret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+
+		var req cloudRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+
+		if req.FolderID != "b1gfolder" || req.SourceLanguageCode != "ja" || req.TargetLanguageCode != "en" || req.Format != "PLAIN_TEXT" {
+			t.Errorf("unexpected request %+v", req)
+		}
+
+		lock.Lock()
+		batches++
+		lock.Unlock()
+
+		var resp cloudResponse
+		for _, text := range req.Texts {
+			resp.Translations = append(resp.Translations, struct {
+				Text                 string `json:"text"`
+				DetectedLanguageCode string `json:"detectedLanguageCode"`
+			}{Text: "en " + text})
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	translatortest.Batched(t, tr, []string{"一", "二", "三"}, func(text string) string { return "en " + text }, func() int {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return batches
+	})
+}
+
+func TestTranslate_CloudIAMToken(t *testing.T) {
+	tr := newTestTranslate(t, "t1.token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t1.token" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+
+		fmt.Fprint(w, `{"translations": [{"text": "Hello"}]}`)
+	})
+
+	if out, err := tr.Translate(&translator.Request{Text: "こんにちは", From: "ja", To: "en"}); err != nil || out != "Hello" {
+		t.Errorf("Translate() = %q, %v", out, err)
+	}
+}
+
+func TestTranslate_CloudErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   interface{}
+	}{
+		{"bad language", http.StatusBadRequest, `{"code": 3, "message": "unsupported target_language_code: xx"}`, &translator.UnsupportedError{}},
+		{"bad key", http.StatusUnauthorized, `{"code": 16, "message": "Unknown api key"}`, &translator.AuthFailedError{}},
+		{"quota", http.StatusTooManyRequests, `{"code": 8, "message": "Quota limit exceeded"}`, &translator.RateLimitedError{}},
+		{"billing", http.StatusBadRequest, `{"code": 9, "message": "The billing account is not active"}`, &translator.BlockedError{}},
+		{"server error", http.StatusServiceUnavailable, `{"code": 14, "message": "Service unavailable"}`, &translator.TransientError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestTranslate(t, "AQVNsecret", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+
+			_, err := tr.Translate(&translator.Request{Text: "おはよう", From: "ja", To: "xx"})
+			if !errors.As(err, tt.want) {
+				t.Errorf("Translate() error = %#v, want %T", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslate_Start(t *testing.T) {
+	tests := []struct {
+		name      string
+		conf      config.TranslatorConfig
+		wantCloud bool
+		wantErr   bool
+	}{
+		{"no key", config.TranslatorConfig{}, false, true},
+		{"old key", config.TranslatorConfig{Key: "trnsl.1.1.old"}, false, false},
+		{"api key", config.TranslatorConfig{Key: "AQVNsecret"}, true, false},
+		{"legacy api", config.TranslatorConfig{Key: "AQVNsecret", API: "legacy"}, false, false},
+		{"cloud api with old looking key", config.TranslatorConfig{Key: "trnsl.1.1.old", API: "Cloud"}, true, false},
+		{"unknown api", config.TranslatorConfig{Key: "AQVNsecret", API: "v2"}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := New()
+
+			err := tr.Start(tt.conf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Start() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tr.Enabled() == tt.wantErr || tr.cloud != tt.wantCloud || (tt.wantCloud && tr.cloudURL != cloudURL) {
+				t.Errorf("Start() cloud = %v, url = %q, enabled = %v", tr.cloud, tr.cloudURL, tr.Enabled())
+			}
+		})
+	}
+}
@@ -20,6 +20,9 @@ import (
 const (
 	apiURL = "https://translate.yandex.net/api/v1.5/tr.json/translate"
 	delay  = time.Second
+
+	// Keys for v1.5 api start with this, it's used when api isn't set
+	legacyKeyPrefix = "trnsl."
 )
 
 type Translate struct {
@@ -29,6 +32,12 @@ type Translate struct {
 	mutex       *sync.Mutex
 
 	apiKey string
+
+	// Yandex Cloud api is used unless legacy one is configured or key is an old trnsl. one
+	cloud    bool
+	cloudURL string
+	folderID string
+	batcher  *translator.Batcher
 }
 
 type yandexResponse struct {
@@ -37,6 +46,26 @@ type yandexResponse struct {
 	Text []string `json:"text"`
 }
 
+// statusError maps v1.5 api error codes https://tech.yandex.com/translate/doc/dg/reference/translate-docpage
+func statusError(resp *http.Response, body []byte, req *translator.Request) error {
+	msg := fmt.Sprintf("%s - %s", resp.Status, strings.TrimSpace(string(body)))
+
+	switch resp.StatusCode {
+	case 401:
+		return translator.AuthFailedError{Message: msg}
+	case 402:
+		return translator.BlockedError{Message: "api key blocked: " + msg}
+	case 404:
+		return translator.BlockedError{Message: "daily limit exceeded: " + msg}
+	case 422:
+		return translator.BadTranslationError{Input: req.Text}
+	case 501:
+		return translator.UnsupportedError{From: req.From, To: req.To, Message: msg}
+	}
+
+	return translator.StatusError(resp, body)
+}
+
 func New() *Translate {
 	return &Translate{
 		client:      &http.Client{Timeout: (10 * time.Second)},
@@ -51,13 +80,27 @@ func (t *Translate) Name() string {
 
 func (t *Translate) Start(c config.TranslatorConfig) error {
 	t.apiKey = c.Key
-	if len(t.apiKey) < 1 || !strings.HasPrefix(t.apiKey, "trnsl.") {
+	if len(t.apiKey) < 1 {
 		return fmt.Errorf("%s: Invalid api key provided, edit comfy-translator.toml to disable or change key", t.Name())
 	}
 
-	t.enabled = true
+	api := strings.ToLower(c.API)
+	if len(api) < 1 {
+		api = "cloud"
+		if strings.HasPrefix(t.apiKey, legacyKeyPrefix) {
+			api = "legacy"
+		}
+	}
 
-	return nil
+	switch api {
+	case "cloud":
+		return t.startCloud(c)
+	case "legacy":
+		t.enabled = true
+		return nil
+	}
+
+	return fmt.Errorf("%s: Unknown api %q, use cloud or legacy", t.Name(), c.API)
 }
 
 func (t *Translate) Enabled() bool {
@@ -65,6 +108,15 @@ func (t *Translate) Enabled() bool {
 }
 
 func (t *Translate) Translate(req *translator.Request) (string, error) {
+	if t.cloud {
+		return t.batcher.Translate(req)
+	}
+
+	return t.translateLegacy(req)
+}
+
+// translateLegacy uses v1.5 api that only works with keys issued before it was retired
+func (t *Translate) translateLegacy(req *translator.Request) (string, error) {
 	start := time.Now()
 
 	t.mutex.Lock()
@@ -97,12 +149,14 @@ func (t *Translate) Translate(req *translator.Request) (string, error) {
 	resp, err := t.client.Do(r)
 	if err != nil {
 		log.Errorln("Failed to do request", err)
-		return "", err
+		return "", translator.TransientError{Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("%s", resp.Status)
+		contents, _ := ioutil.ReadAll(resp.Body)
+
+		return "", statusError(resp, contents, req)
 	}
 
 	contents, err := ioutil.ReadAll(resp.Body)